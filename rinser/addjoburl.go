@@ -1,12 +1,124 @@
 package rinser
 
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
 type AddJobURL struct {
-	URL           string `json:"url" example:"https://getsamplefiles.com/download/pdf/sample-1.pdf"`
-	Lang          string `json:"lang" example:"auto"`
-	MaxSizeMB     int    `json:"maxsizemb" example:"2048"`
-	MaxTimeSec    int    `json:"maxtimesec" example:"86400"`
-	TimeoutSec    int    `json:"timeoutsec" example:"60"`
-	CleanupSec    int    `json:"cleanupsec" example:"86400"`
-	CleanupGotten bool   `json:"cleanupgotten" example:"true"`
-	Private       bool   `json:"private" example:"false"`
+	URL           string   `json:"url" example:"https://getsamplefiles.com/download/pdf/sample-1.pdf"`
+	Lang          string   `json:"lang" example:"auto"`
+	MaxSizeMB     int      `json:"maxsizemb" example:"2048"`
+	MaxTimeSec    int      `json:"maxtimesec" example:"86400"`
+	TimeoutSec    int      `json:"timeoutsec" example:"60"`
+	CleanupSec    int      `json:"cleanupsec" example:"86400"`
+	CleanupGotten bool     `json:"cleanupgotten" example:"true"`
+	Private       bool     `json:"private" example:"false"`
+	Formats       []string `json:"formats,omitempty" example:"pdfa,txt"`
+	Dpi           int      `json:"dpi,omitempty" example:"300"`
+	ColorMode     string   `json:"colormode,omitempty" example:"gray"`
+	Pages         string   `json:"pages,omitempty" example:"1-5,10,20-"`
+	Stamp         *bool    `json:"stamp,omitempty" example:"true"`
+	Callback      string   `json:"callback,omitempty" example:"https://example.com/rinsed"`
+}
+
+// addJobURLFromQuery returns the job options given as query parameters,
+// using the admin defaults for those that are missing. The default limits
+// are clamped to the quota ceilings of the user.
+func (rns *Rinse) addJobURLFromQuery(hr *http.Request) (a AddJobURL, err error) {
+	email := rns.GetEmail(hr)
+	rns.mu.Lock()
+	a.MaxSizeMB, a.MaxTimeSec = rns.defaultLimitsLocked(email)
+	a.CleanupSec = rns.cleanupSec
+	a.TimeoutSec = rns.timeoutSec
+	a.CleanupGotten = rns.cleanupGotten
+	rns.mu.Unlock()
+
+	q := hr.URL.Query()
+	a.Lang = q.Get("lang")
+
+	if s := q.Get("maxsizemb"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			a.MaxSizeMB = v
+		}
+	}
+
+	if s := q.Get("maxtimesec"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			a.MaxTimeSec = v
+		}
+	}
+
+	if s := q.Get("cleanupsec"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			a.CleanupSec = v
+		}
+	}
+
+	if s := q.Get("timeoutsec"); s != "" {
+		if v, err := strconv.Atoi(s); err == nil {
+			a.TimeoutSec = v
+		}
+	}
+
+	if s := q.Get("cleanupgotten"); s != "" {
+		if v, err := strconv.ParseBool(s); err == nil {
+			a.CleanupGotten = v
+		}
+	}
+
+	if s := q.Get("private"); s != "" {
+		if v, err := strconv.ParseBool(s); err == nil {
+			a.Private = v
+		}
+	}
+
+	a.Formats = q["formats"]
+	a.ColorMode = q.Get("colormode")
+	a.Pages = q.Get("pages")
+	a.Callback = q.Get("callback")
+	if s := strings.TrimSpace(q.Get("dpi")); s != "" {
+		if a.Dpi, err = strconv.Atoi(s); err != nil {
+			return a, ErrIllegalDpi
+		}
+	}
+	if a.Stamp, err = ParseStamp(q.Get("stamp")); err != nil {
+		return
+	}
+	err = a.check()
+	return
+}
+
+// check validates the output format, rendering, page range and
+// callback options and puts them in normal form.
+func (a *AddJobURL) check() (err error) {
+	if a.Formats, err = ParseFormats(a.Formats); err == nil {
+		a.ColorMode = strings.ToLower(strings.TrimSpace(a.ColorMode))
+		if err = CheckRendering(a.Dpi, a.ColorMode); err == nil {
+			if a.Pages, err = ParsePageRanges(a.Pages); err == nil {
+				a.Callback = strings.TrimSpace(a.Callback)
+				err = CheckCallback(a.Callback)
+			}
+		}
+	}
+	return
+}
+
+// newJob creates a job for the document or URL called name using the options.
+// The job's spans become part of the trace in ctx.
+func (a *AddJobURL) newJob(ctx context.Context, rns *Rinse, name, email string) (job *Job, err error) {
+	if job, err = NewJob(rns, name, a.Lang, a.MaxSizeMB, a.MaxTimeSec, a.CleanupSec, a.TimeoutSec, a.CleanupGotten, a.Private, email); err == nil {
+		job.Formats = a.Formats
+		job.Dpi = a.Dpi
+		job.ColorMode = a.ColorMode
+		job.PageRanges = a.Pages
+		job.Stamp = a.Stamp
+		job.Callback = a.Callback
+		job.spanContext = trace.SpanContextFromContext(ctx)
+	}
+	return
 }
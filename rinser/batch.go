@@ -0,0 +1,117 @@
+package rinser
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrEmptyBatch = errors.New("batch has no documents")
+var ErrBatchFailed = errors.New("all jobs in the batch failed")
+
+// Batch is the aggregated state of the jobs added by one batch request.
+type Batch struct {
+	UUID     uuid.UUID `json:"uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"`
+	State    string    `json:"state" example:"running"` // "queued", "running", "finished", "failed" or "partial"
+	Done     bool      `json:"done" example:"false"`    // true once no job is waiting or running
+	Total    int       `json:"total" example:"3"`
+	Queued   int       `json:"queued" example:"1"`
+	Running  int       `json:"running" example:"1"`
+	Finished int       `json:"finished" example:"1"`
+	Failed   int       `json:"failed" example:"0"`
+	Jobs     []*Job    `json:"jobs"`
+}
+
+// BatchJobs returns the jobs in the batch, with the jobs unpacked
+// from a job following it.
+func (rns *Rinse) BatchJobs(id uuid.UUID) (jobs []*Job) {
+	if id != uuid.Nil {
+		rns.mu.Lock()
+		for _, job := range rns.jobs {
+			if job.Batch == id {
+				jobs = append(jobs, job)
+			}
+		}
+		rns.mu.Unlock()
+	}
+	return groupJobs(jobs)
+}
+
+// FindBatch returns the state of the batch with the given UUID,
+// or nil if it has no jobs.
+func (rns *Rinse) FindBatch(s string) (batch *Batch) {
+	if id, err := uuid.Parse(s); err == nil {
+		if jobs := rns.BatchJobs(id); len(jobs) > 0 {
+			batch = newBatch(id, jobs)
+		}
+	}
+	return
+}
+
+func newBatch(id uuid.UUID, jobs []*Job) (batch *Batch) {
+	batch = &Batch{UUID: id, Total: len(jobs), Jobs: jobs}
+	for _, job := range jobs {
+		switch job.State() {
+		case JobNew:
+			batch.Queued++
+		case JobFinished:
+			batch.Finished++
+		case JobFailed:
+			batch.Failed++
+		default:
+			batch.Running++
+		}
+	}
+	batch.Done = batch.Queued+batch.Running == 0
+	switch {
+	case batch.Running > 0 || (batch.Queued > 0 && batch.Queued < batch.Total):
+		batch.State = "running"
+	case batch.Queued > 0:
+		batch.State = "queued"
+	case batch.Failed == 0:
+		batch.State = "finished"
+	case batch.Finished == 0:
+		batch.State = "failed"
+	default:
+		batch.State = "partial"
+	}
+	return
+}
+
+// batchDir returns the directory within the batch ZIP file
+// that holds the documents unpacked from the job.
+func batchDir(job *Job, jobs map[uuid.UUID]*Job) (dir string) {
+	dir = strings.TrimSuffix(job.CombinedName(), ".zip")
+	if parent := jobs[job.Parent]; parent != nil {
+		dir = path.Join(batchDir(parent, jobs), path.Dir(job.Name), dir)
+	}
+	return
+}
+
+// writeBatch writes a ZIP file with the rinsed documents of the finished jobs
+// in the batch. Documents unpacked from an archive or email are placed in
+// a directory named after it.
+func writeBatch(w io.Writer, jobs []*Job) (err error) {
+	byUUID := make(map[uuid.UUID]*Job)
+	for _, job := range jobs {
+		byUUID[job.UUID] = job
+	}
+	zw := zip.NewWriter(w)
+	var names []string
+	for _, job := range jobs {
+		if job.State() == JobFinished && !job.IsArchive() {
+			name := job.ResultName()
+			if parent := byUUID[job.Parent]; parent != nil {
+				name = path.Join(batchDir(parent, byUUID), path.Dir(job.Name), name)
+			}
+			if names, err = addZipResult(zw, names, name, job); err != nil {
+				return
+			}
+		}
+	}
+	return zw.Close()
+}
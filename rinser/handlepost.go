@@ -10,11 +10,17 @@ import (
 	"path/filepath"
 
 	"github.com/linkdata/jaws/lib/ui"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const FormFileKey = "file"
 const FormLangKey = "lang"
 const FormURLKey = "url"
+const FormFormatKey = "format"
+const FormDpiKey = "dpi"
+const FormColorModeKey = "colormode"
+const FormPagesKey = "pages"
+const FormStampKey = "stamp"
 
 var ErrContentEncoded = errors.New("Content-Encoding is set")
 
@@ -30,6 +36,42 @@ func (rns *Rinse) FormURLKey() string {
 	return FormURLKey
 }
 
+func (rns *Rinse) FormFormatKey() string {
+	return FormFormatKey
+}
+
+func (rns *Rinse) FormDpiKey() string {
+	return FormDpiKey
+}
+
+func (rns *Rinse) FormColorModeKey() string {
+	return FormColorModeKey
+}
+
+func (rns *Rinse) FormPagesKey() string {
+	return FormPagesKey
+}
+
+func (rns *Rinse) FormStampKey() string {
+	return FormStampKey
+}
+
+func (rns *Rinse) FormatNames() []string {
+	return FormatNames()
+}
+
+func (rns *Rinse) DpiChoices() []int {
+	return []int{75, 150, 200, 300, 400, 600}
+}
+
+func (rns *Rinse) ColorModes() []string {
+	return ColorModes
+}
+
+func (rns *Rinse) ColorModeName(colorMode string) string {
+	return colorModeNames[colorMode]
+}
+
 func mustNotBeContentEncoded(r *http.Request) error {
 	if r.Header.Get("Content-Encoding") == "" {
 		return nil
@@ -47,18 +89,38 @@ func (rns *Rinse) handlePost(interactive bool, w http.ResponseWriter, r *http.Re
 		returnUrl = "/api/"
 	}
 
+	ctx, span := rns.startHTTPSpan(r, "handlePost")
+	defer span.End()
+
+	email := rns.GetEmail(r)
 	rns.mu.Lock()
-	maxSizeMB := rns.maxSizeMB
-	maxTimeSec := rns.maxTimeSec
+	maxSizeMB, maxTimeSec := rns.defaultLimitsLocked(email)
 	cleanupSec := rns.cleanupSec
 	timeoutSec := rns.timeoutSec
 	cleanupGotten := rns.cleanupGotten
 	rns.mu.Unlock()
 
-	email := rns.GetEmail(r)
+	formats, e := ParseFormats(r.Form[FormFormatKey])
+	var dpi int
+	var colorMode, pageRanges string
+	var stamp *bool
+	if e == nil {
+		dpi, colorMode, e = parseRendering(r.FormValue(FormDpiKey), r.FormValue(FormColorModeKey)) // #nosec G120
+	}
+	if e == nil {
+		pageRanges, e = ParsePageRanges(r.FormValue(FormPagesKey)) // #nosec G120
+	}
+	if e == nil {
+		stamp, e = ParseStamp(r.FormValue(FormStampKey)) // #nosec G120
+	}
+	if e == nil {
+		e = rns.CheckQuota(email, maxSizeMB, maxTimeSec)
+	}
 
 	var job *Job
-	if err == nil && info != nil {
+	if e != nil {
+		err = e
+	} else if err == nil && info != nil {
 		if err = mustNotBeContentEncoded(r); err == nil {
 			srcName := filepath.Base(info.Filename)
 			srcFile := srcFormFile.(io.ReadCloser)
@@ -74,7 +136,9 @@ func (rns *Rinse) handlePost(interactive bool, w http.ResponseWriter, r *http.Re
 				if dstFile, err = os.Create(dstName); err == nil {
 					defer dstFile.Close()
 					if _, err = io.Copy(dstFile, srcFile); err == nil {
-						err = dstFile.Sync()
+						if err = dstFile.Sync(); err == nil {
+							_, err = job.DocumentFile()
+						}
 					}
 				}
 			}
@@ -88,6 +152,12 @@ func (rns *Rinse) handlePost(interactive bool, w http.ResponseWriter, r *http.Re
 
 	if job != nil {
 		if err == nil {
+			job.Formats = formats
+			job.Dpi = dpi
+			job.ColorMode = colorMode
+			job.PageRanges = pageRanges
+			job.Stamp = stamp
+			job.spanContext = trace.SpanContextFromContext(ctx)
 			if err = rns.AddJob(job); err == nil {
 				if interactive {
 					w.Header().Add("Location", returnUrl)
@@ -104,5 +174,5 @@ func (rns *Rinse) handlePost(interactive bool, w http.ResponseWriter, r *http.Re
 		ui.Handler(rns.Jaws, "error.html", errorHTML{Rinse: rns, Error: err}).ServeHTTP(w, r)
 		return
 	}
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(documentHTTPStatus(err, http.StatusBadRequest))
 }
@@ -2,6 +2,7 @@ package rinser
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -13,6 +14,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/linkdata/deadlock"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type JobState int
@@ -29,6 +32,10 @@ const (
 	JobEnding
 	JobFinished
 	JobFailed
+	JobUnpack
+	JobCache
+	JobScan
+	JobStamp
 )
 
 type Job struct {
@@ -45,24 +52,56 @@ type Job struct {
 	CleanupGotten bool           `json:"cleanupgotten" example:"true"`
 	Private       bool           `json:"private" example:"false"`
 	Email         string         `json:"email,omitempty" example:"user@example.com"`
-	StoppedCh     chan struct{}  `json:"-"` // closed when job stopped
+	Parent        uuid.UUID      `json:"parent,omitzero" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"`
+	Depth         int            `json:"depth,omitempty" example:"0"`
+	Batch         uuid.UUID      `json:"batch,omitzero" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"`
+	Callback      string         `json:"callback,omitempty" example:"https://example.com/rinsed"`
+	Formats       []string       `json:"formats,omitempty" example:"txt,hocr"`
+	Dpi           int            `json:"dpi,omitempty" example:"300"`
+	ColorMode     string         `json:"colormode,omitempty" example:"gray"`
+	PageRanges    string         `json:"pageranges,omitempty" example:"1-5,10,20-"`
+	Stamp         *bool          `json:"stamp,omitempty" example:"true"`
+	saveMu        deadlock.Mutex // serializes job store writes
 	mu            deadlock.Mutex // protects following
 	Error         error          `json:"error,omitempty"`
 	PdfName       string         `json:"pdfname,omitempty" example:"example-docx-rinsed.pdf"` // rinsed PDF file name
+	MimeType      string         `json:"mimetype,omitempty" example:"application/pdf"`        // detected document type
+	InputSHA256   string         `json:"inputsha256,omitempty" example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"`
+	OutputSHA256  string         `json:"outputsha256,omitempty" example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"`
 	Language      string         `json:"lang,omitempty" example:"auto"`
 	Done          bool           `json:"done,omitempty" example:"false"`
 	Diskuse       int64          `json:"diskuse,omitempty" example:"1234"`
-	Pages         int            `json:"pages,omitempty" example:"1"`
+	Pages         int            `json:"pages,omitempty" example:"1"`      // pages selected for rinsing
+	TotalPages    int            `json:"totalpages,omitempty" example:"1"` // pages in the document
 	Downloads     int            `json:"downloads,omitempty" example:"0"`
+	Retries       int            `json:"retries,omitempty" example:"0"`       // automatic retries
+	ManualRetries int            `json:"manualretries,omitempty" example:"0"` // retries asked for by users
+	Priority      int            `json:"priority,omitempty" example:"0"`
+	queueSeq      int            // where an admin moved the job in the queue, zero if not moved
+	Children      int            `json:"children,omitempty" example:"0"` // documents unpacked from an archive
+	History       []StageRecord  `json:"history,omitempty"`
+	Cached        bool           `json:"cached,omitempty" example:"false"`  // finished using a cached result
+	Verdict       string         `json:"verdict,omitempty" example:"clean"` // of the malware scan, if scanned
+	Malware       string         `json:"malware,omitempty" example:"Eicar-Test-Signature"`
+	Quarantined   bool           `json:"quarantined,omitempty" example:"false"` // only admins may download the results
+	Risk          *Risk          `json:"risk,omitempty"`                        // of the content found in the document metadata
 	started       time.Time
 	progress      time.Time // when we last saw progress being made
 	stopped       time.Time
-	docName       string // document file name, once known
+	docName       string            // document file name, once known
+	workExt       string            // working file extension, if not that of docName
+	outputHashes  map[string]string // SHA-256 of each output, by format
+	cacheKey      string            // of the cached result, if the cache was looked in
+	spanContext   trace.SpanContext // of the request that added the job
 	state         JobState
 	imgfiles      map[string]bool
 	cancelFn      context.CancelFunc
 	closed        bool
+	shutdown      bool // service is shutting down, stop recording
 	errstate      JobState
+	resume        JobState      // stage to resume from, JobNew to run all stages
+	retryAt       time.Time     // when to automatically retry a failed job
+	stoppedCh     chan struct{} // closed when the current run of the job stopped
 	previews      map[uint64][]byte
 }
 
@@ -83,7 +122,7 @@ func NewJob(rns *Rinse, name, lang string, maxsizemb, maxtimesec, cleanupsec, ti
 			lang = ""
 		}
 		id := uuid.New()
-		workDir := path.Join(os.TempDir(), "rinse-"+id.String())
+		workDir := jobWorkdir(id)
 		if err = os.Mkdir(workDir, 0777); err == nil /* #nosec G301 */ {
 			dataDir := path.Join(workDir, "data")
 			if err = os.Mkdir(dataDir, 0777); err == nil /* #nosec G301 */ {
@@ -103,7 +142,7 @@ func NewJob(rns *Rinse, name, lang string, maxsizemb, maxtimesec, cleanupsec, ti
 					Private:       private,
 					Email:         email,
 					state:         JobNew,
-					StoppedCh:     make(chan struct{}),
+					stoppedCh:     make(chan struct{}),
 					imgfiles:      make(map[string]bool),
 					previews:      make(map[uint64][]byte),
 				}
@@ -113,8 +152,13 @@ func NewJob(rns *Rinse, name, lang string, maxsizemb, maxtimesec, cleanupsec, ti
 	return
 }
 
+func fileExists(fpath string) bool {
+	_, err := os.Stat(fpath)
+	return err == nil
+}
+
 func (job *Job) HasMeta() (yes bool) {
-	if job.State() > JobExtractMeta {
+	if state := job.State(); state != JobNew && state != JobExtractMeta {
 		if _, e := os.Stat(job.MetaPath()); e == nil {
 			yes = true
 		}
@@ -156,6 +200,17 @@ func (job *Job) Start() (err error) {
 	return
 }
 
+// jobJSON has the fields of Job but not its MarshalJSON method.
+type jobJSON Job
+
+// MarshalJSON marshals the job while holding its lock, so that the
+// fields the running job changes, such as History, are consistent.
+func (job *Job) MarshalJSON() ([]byte, error) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return json.Marshal((*jobJSON)(job))
+}
+
 func (job *Job) State() (state JobState) {
 	job.mu.Lock()
 	state = job.state
@@ -205,15 +260,26 @@ func (job *Job) ResultPath() string {
 
 func (job *Job) transition(ctx context.Context, fromState, toState JobState) (err error) {
 	if err = ctx.Err(); err == nil {
+		now := time.Now()
 		job.mu.Lock()
 		if job.state == fromState {
 			job.state = toState
-			job.progress = time.Now()
+			job.progress = now
 		} else {
 			err = fmt.Errorf("expected job state %d, have %d", fromState, job.state)
 		}
 		job.mu.Unlock()
 		job.refreshDiskuse()
+		if err == nil {
+			job.mu.Lock()
+			job.recordStageLocked(toState, now)
+			job.mu.Unlock()
+			job.Rinse.Jaws.Dirty(uiJobHistory{job})
+		}
+		job.save()
+		if err == nil {
+			job.publishEvent(JobEventState)
+		}
 	}
 	return
 }
@@ -230,7 +296,20 @@ func (job *Job) madeProgressHandler(string, bool) error {
 }
 
 func (job *Job) runsc(ctx context.Context, stdouthandler func(string, bool) error, cmds ...string) (err error) {
-	if err = runsc(ctx, job.Rinse.RunscBin, job.Rinse.RootDir, job.Workdir, job.LogPath(), job.UUID.String(), stdouthandler, cmds...); err != nil {
+	return job.runscBundle(ctx, job.Workdir, job.UUID.String(), nil, stdouthandler, cmds...)
+}
+
+// runscBundle runs a sandbox using the OCI bundle in bundleDir, allowing
+// several sandboxes to run concurrently for the same job.
+func (job *Job) runscBundle(ctx context.Context, bundleDir, id string, env []string, stdouthandler func(string, bool) error, cmds ...string) (err error) {
+	ctx, span := job.startSpan(ctx, "runsc", trace.WithAttributes(
+		attribute.String("rinse.runsc.id", id),
+		attribute.StringSlice("rinse.runsc.command", cmds),
+	))
+	err = runscBundle(ctx, job.Rinse.RunscBin, job.Rinse.RootDir, bundleDir, job.Datadir, job.LogPath(), id, env, stdouthandler, cmds...)
+	span.SetAttributes(attribute.Int("rinse.runsc.exit_code", job.recordExit(err)))
+	endSpan(span, err)
+	if err != nil {
 		if !(errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)) {
 			job.Rinse.Error("runsc", "err", err, "log", job.LogPath())
 		}
@@ -239,6 +318,7 @@ func (job *Job) runsc(ctx context.Context, stdouthandler func(string, bool) erro
 }
 
 func (job *Job) removeAll() {
+	job.deleteRecord()
 	if err := scrub(job.Workdir); err != nil {
 		job.Rinse.Error("job.removeAll", "job", job.Name, "err", err)
 	}
@@ -263,6 +343,18 @@ func (job *Job) Close(err error) {
 	}
 }
 
+// stop cancels a running job without removing it or recording
+// any further changes, leaving it to be reloaded on next startup.
+func (job *Job) stop() {
+	job.mu.Lock()
+	job.shutdown = true
+	cancel := job.cancelFn
+	job.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
 func (job *Job) refreshDiskuse() {
 	var imgfiles []string
 	var diskuse int64
@@ -278,23 +370,32 @@ func (job *Job) refreshDiskuse() {
 		return nil
 	})
 	now := time.Now()
+	rendered := false
 	job.mu.Lock()
 	job.Diskuse = diskuse
 	for _, fn := range imgfiles {
 		if _, ok := job.imgfiles[fn]; !ok {
 			job.imgfiles[fn] = false
 			job.progress = now
+			rendered = true
 		}
 	}
 	job.mu.Unlock()
+	if rendered {
+		job.publishEvent(JobEventRendered)
+	}
 	job.Rinse.Jaws.Dirty(job, uiJobStatus{job})
 }
 
-func (job *Job) downloaded() {
+// downloaded counts a download of the job output. Only getting
+// the rinsed PDF removes the job if CleanupGotten is set.
+func (job *Job) downloaded(rinsed bool) {
 	job.mu.Lock()
 	job.Downloads++
 	job.mu.Unlock()
-	if job.CleanupGotten {
+	metricDownloads.Inc()
+	job.save()
+	if rinsed && job.CleanupGotten {
 		job.Rinse.RemoveJob(job)
 	}
 }
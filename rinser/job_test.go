@@ -0,0 +1,39 @@
+package rinser
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestJobMarshalJSONWhileRunning(t *testing.T) {
+	job := &Job{Name: "doc.pdf", UUID: uuid.New()}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 1000 {
+			job.mu.Lock()
+			job.recordStageLocked(JobDownload+JobState(i%2), time.Now())
+			job.Pages = i
+			job.mu.Unlock()
+		}
+	}()
+	for range 100 {
+		if _, err := json.Marshal(WebhookPayload{Event: WebhookEventFinished, Job: job}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	b, err := json.Marshal(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); !strings.Contains(s, `"history":[`) || !strings.Contains(s, `"pages":999`) {
+		t.Errorf("%s", s)
+	}
+}
@@ -0,0 +1,276 @@
+package rinser
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrCacheMismatch = errors.New("cached result does not match its hash")
+
+// CacheLimits controls the cache of rinsed results, which lets jobs for a document
+// identical to one rinsed recently with the same options finish at once.
+type CacheLimits struct {
+	MaxSizeMB int  // most disk space used by cached results, zero disables the cache
+	TTLSec    int  // how long a result is kept after it was cached
+	Private   bool // also cache results of private jobs
+}
+
+// cacheEntry is stored with each cached result.
+type cacheEntry struct {
+	Created    time.Time
+	Language   string
+	Pages      int
+	TotalPages int
+	Outputs    map[string]string // SHA-256 of each output, by format
+	Meta       bool              // document metadata is cached
+	Risk       *Risk             `json:",omitempty"`
+}
+
+const cacheEntryName = "entry.json"
+const cacheMetaName = "meta.json"
+
+// cacheEvictInterval is how often expired results are removed.
+const cacheEvictInterval = time.Minute
+
+func (rns *Rinse) CacheDir() string {
+	return path.Join(rns.Config.DataDir, "cache")
+}
+
+func (rns *Rinse) CacheLimits() (limits CacheLimits) {
+	rns.mu.Lock()
+	limits = rns.cacheLimits
+	rns.mu.Unlock()
+	return
+}
+
+// cacheable returns true if the results of the job may be cached, and the job may use cached results.
+// Stamped pages name the job, so their results are never shared.
+func (job *Job) cacheable() (yes bool) {
+	limits := job.Rinse.CacheLimits()
+	stamped := job.stamped()
+	job.mu.Lock()
+	yes = !stamped && limits.MaxSizeMB > 0 && (limits.Private || !job.Private) && job.Children == 0 && job.InputSHA256 != ""
+	job.mu.Unlock()
+	return
+}
+
+// resultKey returns the key of the cached result for the job, which depends
+// on the document and every option that changes the result.
+func (job *Job) resultKey() string {
+	dpi, colorMode, maxPixels := job.rendering()
+	var signer string
+	if job.Rinse.signer != nil {
+		signer = job.Rinse.signer.fingerprint()
+	}
+	job.mu.Lock()
+	s := fmt.Sprintf("%s\n%s\n%d\n%s\n%v\n%s\n%s\n%s", job.InputSHA256, job.Language, dpi, colorMode, maxPixels,
+		job.PageRanges, strings.Join(job.Formats, ","), signer)
+	job.mu.Unlock()
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+// copyFile copies src to dst and returns the SHA-256 of the data copied.
+func copyFile(dst, src string) (hash string, err error) {
+	var sf *os.File
+	if sf, err = os.Open(src); err == nil /* #nosec G304 */ {
+		defer sf.Close()
+		var df *os.File
+		if df, err = os.Create(dst); err == nil /* #nosec G304 */ {
+			h := sha256.New()
+			if _, err = io.Copy(io.MultiWriter(df, h), sf); err == nil {
+				hash = hex.EncodeToString(h.Sum(nil))
+			}
+			if e := df.Close(); err == nil {
+				err = e
+			}
+		}
+	}
+	return
+}
+
+func readCacheEntry(dir string) (entry cacheEntry, err error) {
+	var b []byte
+	if b, err = os.ReadFile(path.Join(dir, cacheEntryName)); err == nil /* #nosec G304 */ {
+		err = json.Unmarshal(b, &entry)
+	}
+	return
+}
+
+// copyCached copies the cached outputs in dir to the job data directory,
+// named as they are before the ending stage.
+func (job *Job) copyCached(dir string, entry *cacheEntry) (err error) {
+	for _, format := range job.OutputFormats() {
+		name := "output" + outputFormats[format].ext
+		var hash string
+		if hash, err = copyFile(path.Join(job.Datadir, name), path.Join(dir, name)); err == nil && hash != entry.Outputs[format] {
+			err = ErrCacheMismatch
+		}
+		if err != nil {
+			return
+		}
+	}
+	if entry.Meta {
+		_, err = copyFile(job.MetaPath(), path.Join(dir, cacheMetaName))
+	}
+	return
+}
+
+// runCache finishes the job using a cached result, if one exists.
+func (job *Job) runCache(ctx context.Context) (err error) {
+	if _, err = job.DocumentFile(); err == nil && job.cacheable() {
+		rns := job.Rinse
+		key := job.resultKey()
+		job.mu.Lock()
+		job.cacheKey = key
+		job.mu.Unlock()
+		dir := path.Join(rns.CacheDir(), key)
+		limits := rns.CacheLimits()
+		rns.cacheMu.Lock()
+		defer rns.cacheMu.Unlock()
+		entry, e := readCacheEntry(dir)
+		if e == nil && time.Since(entry.Created) < time.Duration(limits.TTLSec)*time.Second {
+			if e = job.copyCached(dir, &entry); e == nil {
+				job.mu.Lock()
+				job.Cached = true
+				job.Language = entry.Language
+				job.Pages = entry.Pages
+				job.TotalPages = entry.TotalPages
+				job.mu.Unlock()
+				if entry.Risk != nil {
+					job.putRisk(entry.Risk)
+				}
+				if err = job.jobEnding(ctx); err == nil {
+					rns.Info("cached result used", "job", job.Name)
+					err = ErrPipelineDone
+				}
+				return
+			}
+			rns.Error("runCache", "job", job.Name, "err", e)
+			for _, format := range job.OutputFormats() {
+				_ = scrub(path.Join(job.Datadir, "output"+outputFormats[format].ext))
+			}
+		}
+		if e == nil || !errors.Is(e, os.ErrNotExist) {
+			_ = scrub(dir)
+		}
+	}
+	return
+}
+
+// storeCache adds the results of the finished job to the cache,
+// if the job looked for a cached result and found none.
+func (job *Job) storeCache() (err error) {
+	job.mu.Lock()
+	key := job.cacheKey
+	cached := job.Cached
+	job.mu.Unlock()
+	if key != "" && !cached && job.cacheable() {
+		rns := job.Rinse
+		dir := path.Join(rns.CacheDir(), key)
+		tmpdir := dir + ".tmp"
+		rns.cacheMu.Lock()
+		defer rns.cacheMu.Unlock()
+		_ = scrub(tmpdir)
+		if err = os.MkdirAll(tmpdir, 0750); err == nil /* #nosec G301 */ {
+			job.mu.Lock()
+			entry := cacheEntry{
+				Created:    time.Now(),
+				Language:   job.Language,
+				Pages:      job.Pages,
+				TotalPages: job.TotalPages,
+				Outputs:    job.outputHashes,
+				Risk:       job.Risk,
+			}
+			job.mu.Unlock()
+			for _, format := range job.OutputFormats() {
+				if err == nil {
+					_, err = copyFile(path.Join(tmpdir, "output"+outputFormats[format].ext), path.Join(job.Datadir, job.OutputName(format)))
+				}
+			}
+			if err == nil && fileExists(job.MetaPath()) {
+				if _, err = copyFile(path.Join(tmpdir, cacheMetaName), job.MetaPath()); err == nil {
+					entry.Meta = true
+				}
+			}
+			var b []byte
+			if err == nil {
+				if b, err = json.Marshal(entry); err == nil {
+					if err = os.WriteFile(path.Join(tmpdir, cacheEntryName), b, 0640); err == nil /* #nosec G306 */ {
+						_ = scrub(dir)
+						err = os.Rename(tmpdir, dir)
+					}
+				}
+			}
+			if err != nil {
+				_ = scrub(tmpdir)
+			}
+			rns.evictCacheLocked()
+		}
+	}
+	return
+}
+
+type cachedResult struct {
+	dir     string
+	created time.Time
+	size    int64
+}
+
+// evictCacheLocked removes expired results, and then the oldest
+// results until the cache fits in CacheLimits.MaxSizeMB.
+func (rns *Rinse) evictCacheLocked() {
+	limits := rns.CacheLimits()
+	maxSize := int64(limits.MaxSizeMB) * 1024 * 1024
+	ttl := time.Duration(limits.TTLSec) * time.Second
+	rns.cacheEvicted = time.Now()
+	entries, err := os.ReadDir(rns.CacheDir())
+	if err != nil {
+		return
+	}
+	var results []cachedResult
+	var total int64
+	for _, de := range entries {
+		dir := path.Join(rns.CacheDir(), de.Name())
+		entry, e := readCacheEntry(dir)
+		if e != nil || maxSize < 1 || time.Since(entry.Created) >= ttl {
+			if e = scrub(dir); e != nil {
+				rns.Error("evictCache", "dir", dir, "err", e)
+			}
+			continue
+		}
+		_, size := unpackedUsage(dir)
+		total += size
+		results = append(results, cachedResult{dir: dir, created: entry.Created, size: size})
+	}
+	slices.SortFunc(results, func(a, b cachedResult) int { return a.created.Compare(b.created) })
+	for _, r := range results {
+		if total <= maxSize {
+			break
+		}
+		if e := scrub(r.dir); e == nil {
+			total -= r.size
+		} else {
+			rns.Error("evictCache", "dir", r.dir, "err", e)
+		}
+	}
+}
+
+// evictCache removes expired results, at most once every cacheEvictInterval.
+func (rns *Rinse) evictCache() {
+	rns.cacheMu.Lock()
+	defer rns.cacheMu.Unlock()
+	if time.Since(rns.cacheEvicted) >= cacheEvictInterval {
+		rns.evictCacheLocked()
+	}
+}
@@ -0,0 +1,385 @@
+package rinser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+var ErrDocumentTypeNotAllowed = errors.New("document type not allowed")
+var ErrIllegalDocumentType = errors.New("illegal document type")
+
+// DefaultAllowedTypes lists the MIME types of documents accepted when no
+// allowlist is configured. A type ending in "*" matches any type with that prefix.
+var DefaultAllowedTypes = []string{
+	"application/pdf",
+	"application/rtf",
+	"application/msword",
+	"application/vnd.ms-excel",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.ms-outlook",
+	"application/vnd.openxmlformats-officedocument.*",
+	"application/x-tika-ooxml-protected",
+	"application/vnd.oasis.opendocument.*",
+	"application/zip",
+	"application/x-tar",
+	"application/gzip",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/x-7z-compressed",
+	"message/rfc822",
+	"text/plain",
+	"text/csv",
+	"text/html",
+	"text/markdown",
+	"text/xml",
+	"image/*",
+}
+
+// docType is a document type and the file extensions used for it,
+// the first of which is used if the document name has none of them.
+type docType struct {
+	mimeType string
+	exts     []string
+}
+
+var (
+	typePdf     = docType{"application/pdf", []string{".pdf"}}
+	typeRtf     = docType{"application/rtf", []string{".rtf"}}
+	typeOle     = docType{"application/x-ole-storage", []string{".doc"}}
+	typeZip     = docType{"application/zip", []string{".zip"}}
+	type7z      = docType{"application/x-7z-compressed", []string{".7z"}}
+	typeGzip    = docType{"application/gzip", []string{".tar.gz", ".tgz", ".gz"}}
+	typeBzip2   = docType{"application/x-bzip2", []string{".tar.bz2", ".tbz2", ".bz2"}}
+	typeXz      = docType{"application/x-xz", []string{".tar.xz", ".txz", ".xz"}}
+	typeTar     = docType{"application/x-tar", []string{".tar"}}
+	typeTiff    = docType{"image/tiff", []string{".tif", ".tiff"}}
+	typeExe     = docType{"application/vnd.microsoft.portable-executable", []string{".exe", ".dll"}}
+	typeElf     = docType{"application/x-elf", []string{".elf", ".so"}}
+	typeMachO   = docType{"application/x-mach-binary", []string{".macho"}}
+	typeJava    = docType{"application/java-vm", []string{".class"}}
+	typeScript  = docType{"text/x-shellscript", []string{".sh"}}
+	typeUnknown = docType{"application/octet-stream", []string{".bin"}}
+)
+
+// magicTypes maps leading bytes of a file to its type.
+var magicTypes = []struct {
+	magic string
+	dt    docType
+}{
+	{"%PDF-", typePdf},
+	{"{\\rtf", typeRtf},
+	{"\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1", typeOle},
+	{"PK\x03\x04", typeZip},
+	{"PK\x05\x06", typeZip},
+	{"7z\xBC\xAF\x27\x1C", type7z},
+	{"\x1F\x8B", typeGzip},
+	{"BZh", typeBzip2},
+	{"\xFD7zXZ\x00", typeXz},
+	{"II*\x00", typeTiff},
+	{"MM\x00*", typeTiff},
+	{"MZ", typeExe},
+	{"\x7FELF", typeElf},
+	{"\xCF\xFA\xED\xFE", typeMachO},
+	{"\xCE\xFA\xED\xFE", typeMachO},
+	{"\xCA\xFE\xBA\xBE", typeJava},
+	{"#!", typeScript},
+}
+
+// oleStreamTypes are the types of OLE compound files, known by the name
+// of a stream or storage they contain.
+var oleStreamTypes = map[string]docType{
+	"WordDocument":            {"application/msword", []string{".doc", ".dot"}},
+	"Workbook":                {"application/vnd.ms-excel", []string{".xls", ".xlt"}},
+	"Book":                    {"application/vnd.ms-excel", []string{".xls", ".xlt"}},
+	"PowerPoint Document":     {"application/vnd.ms-powerpoint", []string{".ppt", ".pot", ".pps"}},
+	"__properties_version1.0": {"application/vnd.ms-outlook", []string{".msg"}},
+	"VisioDocument":           {"application/vnd.visio", []string{".vsd"}},
+	"Quill":                   {"application/x-mspublisher", []string{".pub"}},
+	"EncryptedPackage":        {"application/x-tika-ooxml-protected", []string{".docx", ".xlsx", ".pptx", ".docm", ".xlsm", ".pptm"}},
+}
+
+// maxOleSectors is the most directory sectors of an OLE compound file read by oleType.
+const maxOleSectors = 64
+
+// oleType returns the type of the OLE compound file f from the names in its
+// directory, or false if none of them are in oleStreamTypes.
+func oleType(f io.ReaderAt) (dt docType, ok bool) {
+	hdr := make([]byte, 512)
+	if _, err := f.ReadAt(hdr, 0); err == nil {
+		shift := binary.LittleEndian.Uint16(hdr[0x1E:])
+		if shift == 9 || shift == 12 {
+			sector := make([]byte, 1<<shift)
+			readSector := func(n uint32) bool {
+				_, err := f.ReadAt(sector, (int64(n)+1)<<shift)
+				return err == nil
+			}
+			// the first 109 FAT sectors are listed in the header
+			next := func(n uint32) uint32 {
+				perSector := uint32(len(sector) / 4)
+				if i := n / perSector; i < 109 && readSector(binary.LittleEndian.Uint32(hdr[0x4C+4*i:])) {
+					return binary.LittleEndian.Uint32(sector[4*(n%perSector):])
+				}
+				return math.MaxUint32
+			}
+			dir := binary.LittleEndian.Uint32(hdr[0x30:])
+			for range maxOleSectors {
+				if dir >= 0xFFFFFFFA || !readSector(dir) {
+					break
+				}
+				for entry := 0; entry+128 <= len(sector); entry += 128 {
+					if n := int(binary.LittleEndian.Uint16(sector[entry+64:])); n >= 2 && n <= 64 {
+						name := make([]uint16, n/2-1)
+						for i := range name {
+							name[i] = binary.LittleEndian.Uint16(sector[entry+2*i:])
+						}
+						if dt, ok = oleStreamTypes[string(utf16.Decode(name))]; ok {
+							return
+						}
+					}
+				}
+				dir = next(dir)
+			}
+		}
+	}
+	return
+}
+
+// oleTypes are the types of OLE compound files, known by their extension.
+var oleTypes = map[string]string{
+	".doc": "application/msword",
+	".dot": "application/msword",
+	".xls": "application/vnd.ms-excel",
+	".xlt": "application/vnd.ms-excel",
+	".ppt": "application/vnd.ms-powerpoint",
+	".pot": "application/vnd.ms-powerpoint",
+	".pps": "application/vnd.ms-powerpoint",
+	".msg": "application/vnd.ms-outlook",
+	".vsd": "application/vnd.visio",
+	".pub": "application/x-mspublisher",
+}
+
+// textTypes are the types of text documents, known by their extension.
+var textTypes = map[string]string{
+	".txt":  "text/plain",
+	".text": "text/plain",
+	".log":  "text/plain",
+	".csv":  "text/csv",
+	".htm":  "text/html",
+	".html": "text/html",
+	".md":   "text/markdown",
+	".xml":  "text/xml",
+	".eml":  "message/rfc822",
+	".svg":  "image/svg+xml",
+	".fodt": "application/vnd.oasis.opendocument.text-flat-xml",
+	".fods": "application/vnd.oasis.opendocument.spreadsheet-flat-xml",
+	".fodp": "application/vnd.oasis.opendocument.presentation-flat-xml",
+}
+
+// zipTypes are the types of ZIP based office documents, known by a file they contain.
+var zipTypes = []struct {
+	entry string
+	dt    docType
+}{
+	{"word/document.xml", docType{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", []string{".docx", ".docm", ".dotx", ".dotm"}}},
+	{"xl/workbook.xml", docType{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", []string{".xlsx", ".xlsm", ".xltx", ".xltm"}}},
+	{"ppt/presentation.xml", docType{"application/vnd.openxmlformats-officedocument.presentationml.presentation", []string{".pptx", ".pptm", ".potx", ".ppsx"}}},
+}
+
+// odfExts maps OpenDocument types to their extension.
+var odfExts = map[string]string{
+	"application/vnd.oasis.opendocument.text":                  ".odt",
+	"application/vnd.oasis.opendocument.text-template":         ".ott",
+	"application/vnd.oasis.opendocument.spreadsheet":           ".ods",
+	"application/vnd.oasis.opendocument.spreadsheet-template":  ".ots",
+	"application/vnd.oasis.opendocument.presentation":          ".odp",
+	"application/vnd.oasis.opendocument.presentation-template": ".otp",
+	"application/vnd.oasis.opendocument.graphics":              ".odg",
+	"application/vnd.oasis.opendocument.formula":               ".odf",
+}
+
+func (dt docType) hasExt(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range dt.exts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// zipType tells OOXML and OpenDocument files from other ZIP files.
+func zipType(fpath string) (dt docType) {
+	dt = typeZip
+	if zr, err := zip.OpenReader(fpath); err == nil {
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.Name == "mimetype" {
+				if rc, err := f.Open(); err == nil {
+					b, _ := io.ReadAll(io.LimitReader(rc, 128))
+					_ = rc.Close()
+					mt := strings.TrimSpace(string(b))
+					if ext, ok := odfExts[mt]; ok {
+						return docType{mt, []string{ext}}
+					}
+				}
+			}
+			for _, zt := range zipTypes {
+				if f.Name == zt.entry {
+					return zt.dt
+				}
+			}
+		}
+	}
+	return
+}
+
+// sniffType returns the type of the file at fpath from its leading bytes.
+// OLE compound files are told apart by the names in their directory, and
+// those that can't be, and text files, by the extension of name.
+func sniffType(fpath, name string) (dt docType, err error) {
+	var f *os.File
+	if f, err = os.Open(fpath); err == nil /* #nosec G304 */ {
+		defer f.Close()
+		buf := make([]byte, 1024)
+		var n int
+		if n, err = io.ReadFull(f, buf); err == io.ErrUnexpectedEOF || err == io.EOF {
+			err = nil
+		}
+		if err == nil {
+			buf = buf[:n]
+			ext := strings.ToLower(filepath.Ext(name))
+			for _, mt := range magicTypes {
+				if bytes.HasPrefix(buf, []byte(mt.magic)) {
+					dt = mt.dt
+					switch dt.mimeType {
+					case typeOle.mimeType:
+						if t, ok := oleType(f); ok {
+							dt = t
+						} else if s, ok := oleTypes[ext]; ok {
+							dt = docType{s, []string{ext}}
+						}
+					case typeZip.mimeType:
+						dt = zipType(fpath)
+					}
+					return
+				}
+			}
+			if len(buf) > 262 && string(buf[257:262]) == "ustar" {
+				return typeTar, nil
+			}
+			if bytes.Contains(buf, []byte("%PDF-")) {
+				return typePdf, nil
+			}
+			mt, _, _ := mime.ParseMediaType(http.DetectContentType(buf))
+			switch {
+			case strings.HasPrefix(mt, "text/"):
+				if s, ok := textTypes[ext]; ok {
+					return docType{s, []string{ext}}, nil
+				}
+				dt = docType{mt, []string{".txt"}}
+				if mt != "text/plain" {
+					dt.exts = []string{"." + strings.TrimPrefix(mt, "text/")}
+				}
+			case mt == typeUnknown.mimeType:
+				dt = typeUnknown
+			default:
+				dt = docType{mt, []string{".bin"}}
+				if exts, e := mime.ExtensionsByType(mt); e == nil && len(exts) > 0 {
+					dt.exts = exts
+				}
+			}
+		}
+	}
+	return
+}
+
+func matchType(pattern, mimeType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(mimeType, prefix)
+	}
+	return pattern == mimeType
+}
+
+// CheckAllowedTypes returns an error if any of types isn't a MIME type
+// such as "application/pdf", optionally ending in "*".
+func CheckAllowedTypes(types []string) error {
+	for _, s := range types {
+		mt, sub, ok := strings.Cut(s, "/")
+		if !ok || mt == "" || sub == "" || strings.ContainsAny(s, " ;,") || strings.Contains(strings.TrimSuffix(s, "*"), "*") {
+			return fmt.Errorf("%w: %q", ErrIllegalDocumentType, s)
+		}
+	}
+	return nil
+}
+
+// AllowedTypes returns the MIME types of documents that jobs accept.
+func (rns *Rinse) AllowedTypes() (types []string) {
+	rns.mu.Lock()
+	types = slices.Clone(rns.allowedTypes)
+	rns.mu.Unlock()
+	if len(types) == 0 {
+		types = slices.Clone(DefaultAllowedTypes)
+	}
+	return
+}
+
+// SetAllowedTypes sets the MIME types of documents that jobs accept.
+// An empty list restores the DefaultAllowedTypes.
+func (rns *Rinse) SetAllowedTypes(types []string) (err error) {
+	if err = CheckAllowedTypes(types); err == nil {
+		rns.mu.Lock()
+		rns.allowedTypes = slices.Clone(types)
+		rns.mu.Unlock()
+	}
+	return
+}
+
+// TypeAllowed returns true if documents of the given MIME type are accepted.
+func (rns *Rinse) TypeAllowed(mimeType string) bool {
+	for _, pattern := range rns.AllowedTypes() {
+		if matchType(pattern, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDocumentType detects the type of the document docName, and returns
+// the extension to use for the working file if the document name doesn't
+// have one matching its type.
+func (job *Job) checkDocumentType(docName string) (mimeType, workExt string, err error) {
+	var dt docType
+	if dt, err = sniffType(filepath.Join(job.Datadir, docName), docName); err == nil {
+		mimeType = dt.mimeType
+		if !job.Rinse.TypeAllowed(mimeType) {
+			err = fmt.Errorf("%w: %s", ErrDocumentTypeNotAllowed, mimeType)
+		} else if !dt.hasExt(docName) {
+			workExt = dt.exts[0]
+			job.Rinse.Warn("document type differs from name", "job", job.Name, "doc", docName, "mimetype", mimeType)
+		}
+	}
+	return
+}
+
+func documentHTTPStatus(err error, code int) int {
+	if errors.Is(err, ErrDocumentTypeNotAllowed) {
+		return http.StatusUnsupportedMediaType
+	}
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return quotaHTTPStatus(err, code)
+}
@@ -0,0 +1,111 @@
+package rinser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+	"unicode/utf16"
+)
+
+// testOle returns an OLE compound file with 512 byte sectors whose
+// directory holds the root entry and the streams in names, one per
+// directory sector so that the directory sector chain is followed.
+func testOle(names ...string) []byte {
+	names = append([]string{"Root Entry"}, names...)
+	sector := func() []byte { return make([]byte, 512) }
+	hdr := sector()
+	copy(hdr, "\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")
+	binary.LittleEndian.PutUint16(hdr[0x1E:], 9)
+	binary.LittleEndian.PutUint32(hdr[0x2C:], 1)
+	binary.LittleEndian.PutUint32(hdr[0x30:], 1)
+	for i := range 109 {
+		binary.LittleEndian.PutUint32(hdr[0x4C+4*i:], 0xFFFFFFFF)
+	}
+	binary.LittleEndian.PutUint32(hdr[0x4C:], 0)
+
+	fat := sector()
+	for i := range 128 {
+		binary.LittleEndian.PutUint32(fat[4*i:], 0xFFFFFFFF)
+	}
+	binary.LittleEndian.PutUint32(fat, 0xFFFFFFFD)
+	var dirs [][]byte
+	for i, name := range names {
+		dir := sector()
+		u := utf16.Encode([]rune(name))
+		for j, c := range u {
+			binary.LittleEndian.PutUint16(dir[2*j:], c)
+		}
+		binary.LittleEndian.PutUint16(dir[64:], uint16(2*len(u)+2)) // #nosec G115
+		next := uint32(i + 2)                                       // #nosec G115
+		if i == len(names)-1 {
+			next = 0xFFFFFFFE
+		}
+		binary.LittleEndian.PutUint32(fat[4*(i+1):], next)
+		dirs = append(dirs, dir)
+	}
+	return bytes.Join(append([][]byte{hdr, fat}, dirs...), nil)
+}
+
+func TestSniffType(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte("hello"))
+	_ = zw.Close()
+
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		mimeType string
+	}{
+		{"upload", testOle("WordDocument"), "application/msword"},
+		{"report.pdf", testOle("\x05SummaryInformation", "Workbook"), "application/vnd.ms-excel"},
+		{"slides", testOle("Pictures", "PowerPoint Document"), "application/vnd.ms-powerpoint"},
+		{"mail", testOle("__nameid_version1.0", "__properties_version1.0"), "application/vnd.ms-outlook"},
+		{"secret.docx", testOle("EncryptionInfo", "EncryptedPackage"), "application/x-tika-ooxml-protected"},
+		{"sheet.xls", testOle("Unknown"), "application/vnd.ms-excel"},
+		{"setup", testOle("Unknown"), "application/x-ole-storage"},
+		{"notes.txt.gz", gz.Bytes(), "application/gzip"},
+	} {
+		fpath := path.Join(dir, "doc")
+		if err := os.WriteFile(fpath, tc.data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		dt, err := sniffType(fpath, tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dt.mimeType != tc.mimeType {
+			t.Errorf("%s: %q, want %q", tc.name, dt.mimeType, tc.mimeType)
+		}
+		if allowed := (&Rinse{}).TypeAllowed(dt.mimeType); allowed != (tc.name != "setup") {
+			t.Errorf("%s: %q allowed %v", tc.name, dt.mimeType, allowed)
+		}
+	}
+}
+
+func TestDecompressedName(t *testing.T) {
+	for _, tc := range []struct {
+		docName, workExt string
+		archive          bool
+		decompressed     string
+	}{
+		{"notes.txt.gz", "", true, "notes.txt"},
+		{"upload", ".tar.gz", true, "upload"},
+		{"data.tgz", "", true, ""},
+		{"report.PDF.XZ", "", true, "report.PDF"},
+		{".gz", "", true, "document"},
+		{"report.pdf", "", false, ""},
+	} {
+		job := &Job{docName: tc.docName, workExt: tc.workExt}
+		if archive := isArchiveName(job.typedName()); archive != tc.archive {
+			t.Errorf("%s%s: archive %v", tc.docName, tc.workExt, archive)
+		}
+		if s := job.decompressedName(); s != tc.decompressed {
+			t.Errorf("%s%s: decompressed name %q, want %q", tc.docName, tc.workExt, s, tc.decompressed)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package rinser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"maps"
+	"os"
+	"path"
+	"strings"
+)
+
+var messageExts = []string{".eml", ".msg"}
+
+func isMessageName(fn string) bool {
+	fn = strings.ToLower(fn)
+	for _, ext := range messageExts {
+		if strings.HasSuffix(fn, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// messageHeaders lists the Tika metadata shown above the message body.
+var messageHeaders = []struct{ key, label string }{
+	{"Message-From", "From"},
+	{"Message-To", "To"},
+	{"Message-Cc", "Cc"},
+	{"dcterms:created", "Date"},
+	{"dc:subject", "Subject"},
+}
+
+func metaString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		var ss []string
+		for _, x := range v {
+			ss = append(ss, metaString(x))
+		}
+		return strings.Join(ss, ", ")
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// messageBody returns the HTML of the message body with the
+// message headers prepended.
+func messageBody(meta map[string]any) []byte {
+	var hdr bytes.Buffer
+	hdr.WriteString("<table>\n")
+	for _, h := range messageHeaders {
+		if s := metaString(meta[h.key]); s != "" {
+			fmt.Fprintf(&hdr, "<tr><th align=\"left\">%s:</th><td>%s</td></tr>\n", h.label, html.EscapeString(s))
+		}
+	}
+	hdr.WriteString("</table>\n<hr>\n")
+	body := metaString(meta["X-TIKA:content"])
+	if i := strings.Index(body, "<body>"); i >= 0 {
+		i += len("<body>")
+		return []byte(body[:i] + hdr.String() + body[i:])
+	}
+	return append([]byte("<html><body>"+hdr.String()), body+"</body></html>"...)
+}
+
+// extractMessageMeta records the risk summary and the Tika metadata of the
+// message and each of its parts, and returns that of the message itself.
+// The recorded metadata leaves out the unrinsed text of the parts.
+func (job *Job) extractMessageMeta(ctx context.Context, fn string) (meta map[string]any, err error) {
+	var buf bytes.Buffer
+	stdouthandler := func(s string, isout bool) (err error) {
+		if isout {
+			job.madeProgress()
+			buf.WriteString(s)
+		}
+		return
+	}
+	if err = job.runsc(ctx, stdouthandler, "java", "-jar", "/usr/local/bin/tika.jar", "--config=/tika-config.xml", "-J", "-h", "/var/rinse/"+fn); err == nil {
+		var parts []map[string]any
+		if err = json.Unmarshal(buf.Bytes(), &parts); err == nil {
+			err = ErrMissingDocument
+			if len(parts) > 0 {
+				meta = maps.Clone(parts[0])
+				risk := assessParts(parts)
+				job.putRisk(&risk)
+				for _, part := range parts {
+					delete(part, "X-TIKA:content")
+				}
+				var b []byte
+				if b, err = json.MarshalIndent(parts, "", "  "); err == nil {
+					err = os.WriteFile(job.MetaPath(), b, 0644) // #nosec G306
+				}
+			}
+		}
+	}
+	return
+}
+
+// unpackMessage adds a child job for each attachment of the message
+// and replaces the message with its body, which the job goes on to rinse.
+func (job *Job) unpackMessage(ctx context.Context, fn string) (err error) {
+	var meta map[string]any
+	if meta, err = job.extractMessageMeta(ctx, fn); err == nil {
+		if err = job.unpack(ctx, fn, func(int64, int) []string {
+			return []string{"java", "-jar", "/usr/local/bin/tika.jar", "--config=/tika-config.xml",
+				"--extract", "--extract-dir=/var/rinse/unpacked", "/var/rinse/" + fn}
+		}); err == nil {
+			if err = os.WriteFile(path.Join(job.Datadir, "input.html"), messageBody(meta), 0644); err == nil /* #nosec G306 */ {
+				if err = scrub(path.Join(job.Datadir, fn)); err == nil {
+					job.mu.Lock()
+					job.workExt = ".html"
+					job.mu.Unlock()
+				}
+			}
+		}
+	}
+	return
+}
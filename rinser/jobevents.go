@@ -0,0 +1,111 @@
+package rinser
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	JobEventState    = "state"    // the job moved to a new stage
+	JobEventRendered = "rendered" // a page was rendered
+	JobEventScanned  = "scanned"  // a page was OCR-ed
+	JobEventFinished = "finished"
+	JobEventFailed   = "failed"
+)
+
+// JobEvent is sent to event stream subscribers when a job changes.
+type JobEvent struct {
+	Event    string    `json:"event" example:"state"`
+	UUID     uuid.UUID `json:"uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"`
+	State    string    `json:"state" example:"Rendering"`
+	Rendered int       `json:"rendered,omitempty" example:"12"` // pages rendered so far
+	Scanned  int       `json:"scanned,omitempty" example:"3"`   // pages OCR-ed so far
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time" example:"2024-01-01T12:00:00+00:00" format:"dateTime"`
+}
+
+// eventSub is an event stream subscriber.
+type eventSub struct {
+	ch      chan JobEvent
+	email   string
+	isAdmin bool
+	job     uuid.UUID // only send events for this job, unless uuid.Nil
+}
+
+// canSee returns true if the subscriber may see the job,
+// using the same rules as JobList.
+func (sub *eventSub) canSee(job *Job) bool {
+	return (sub.job == uuid.Nil || sub.job == job.UUID) && (sub.isAdmin || (!job.Private && job.Email == sub.email))
+}
+
+// subscribeEvents returns a new event stream subscriber for the user.
+// If id is not uuid.Nil, only events for that job are sent.
+func (rns *Rinse) subscribeEvents(email string, id uuid.UUID) (sub *eventSub) {
+	sub = &eventSub{
+		ch:      make(chan JobEvent, 64),
+		email:   email,
+		isAdmin: rns.IsAdmin(email),
+		job:     id,
+	}
+	rns.eventsMu.Lock()
+	if rns.eventSubs == nil {
+		rns.eventSubs = make(map[*eventSub]struct{})
+	}
+	rns.eventSubs[sub] = struct{}{}
+	rns.eventsMu.Unlock()
+	return
+}
+
+func (rns *Rinse) unsubscribeEvents(sub *eventSub) {
+	rns.eventsMu.Lock()
+	delete(rns.eventSubs, sub)
+	rns.eventsMu.Unlock()
+}
+
+// publishEvent sends the event to the subscribers that may see the job.
+// Subscribers that fall behind miss events rather than block the job.
+func (rns *Rinse) publishEvent(job *Job, ev JobEvent) {
+	rns.eventsMu.Lock()
+	defer rns.eventsMu.Unlock()
+	for sub := range rns.eventSubs {
+		if sub.canSee(job) {
+			select {
+			case sub.ch <- ev:
+			default:
+			}
+		}
+	}
+}
+
+// eventLocked returns a JobEvent with the current state of the job.
+func (job *Job) eventLocked(event string) (ev JobEvent) {
+	ev = JobEvent{
+		Event:    event,
+		UUID:     job.UUID,
+		State:    jobStateText(job.state),
+		Rendered: len(job.imgfiles),
+		Time:     time.Now(),
+	}
+	for _, seen := range job.imgfiles {
+		if seen {
+			ev.Scanned++
+		}
+	}
+	if job.Error != nil {
+		ev.Error = job.Error.Error()
+	}
+	return
+}
+
+// Event returns a JobEvent with the current state of the job.
+func (job *Job) Event(event string) (ev JobEvent) {
+	job.mu.Lock()
+	ev = job.eventLocked(event)
+	job.mu.Unlock()
+	return
+}
+
+func (job *Job) publishEvent(event string) {
+	job.Rinse.publishEvent(job, job.Event(event))
+}
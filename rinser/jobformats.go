@@ -0,0 +1,223 @@
+package rinser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrUnknownFormat = errors.New("unknown output format")
+
+type outputFormat struct {
+	config string // tesseract config producing it, if any
+	ext    string // appended to the output base name
+	mime   string
+}
+
+// outputFormats maps output format names to how they are produced.
+// The "pdf" format and the textFormats are always produced.
+var outputFormats = map[string]outputFormat{
+	"pdf":  {config: "pdf", ext: ".pdf", mime: "application/pdf"},
+	"pdfa": {ext: "-pdfa.pdf", mime: "application/pdf"},
+	"txt":  {config: "txt", ext: ".txt", mime: "text/plain; charset=utf-8"},
+	"hocr": {config: "hocr", ext: ".hocr", mime: "text/html; charset=utf-8"},
+	"alto": {config: "alto", ext: ".xml", mime: "application/xml"},
+	"tsv":  {config: "tsv", ext: ".tsv", mime: "text/tab-separated-values; charset=utf-8"},
+}
+
+// FormatNames returns the names of the available output formats, sorted.
+func FormatNames() (names []string) {
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return
+}
+
+// ParseFormats returns the sorted and deduplicated output formats in list,
+// which may contain comma separated names. The "pdf" format is left out
+// since it is always produced.
+func ParseFormats(list []string) (formats []string, err error) {
+	for _, s := range list {
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" && name != "pdf" {
+				if _, ok := outputFormats[name]; !ok {
+					return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+				}
+				if !slices.Contains(formats, name) {
+					formats = append(formats, name)
+				}
+			}
+		}
+	}
+	slices.Sort(formats)
+	return
+}
+
+// textFormats are always produced, so the text of every job can be
+// served from GET /jobs/{uuid}/text.
+var textFormats = []string{"txt", "tsv"}
+
+// OutputFormats returns the output formats the job produces.
+func (job *Job) OutputFormats() (formats []string) {
+	formats = append([]string{"pdf"}, job.Formats...)
+	for _, format := range textFormats {
+		if !slices.Contains(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	return
+}
+
+// HasFormat returns true if the job produces the output format.
+func (job *Job) HasFormat(format string) bool {
+	return format == "pdf" || slices.Contains(textFormats, format) || slices.Contains(job.Formats, format)
+}
+
+// OutputName returns the file name of the output in the given format.
+func (job *Job) OutputName(format string) string {
+	if format == "pdf" {
+		return job.ResultName()
+	}
+	return strings.TrimSuffix(job.ResultName(), ".pdf") + outputFormats[format].ext
+}
+
+func (job *Job) OutputPath(format string) string {
+	return path.Join(job.Datadir, job.OutputName(format))
+}
+
+// tesseractConfigs returns the tesseract configs needed for the job formats.
+func (job *Job) tesseractConfigs() (configs []string) {
+	for _, format := range job.OutputFormats() {
+		if cfg := outputFormats[format].config; cfg != "" {
+			configs = append(configs, cfg)
+		}
+	}
+	return
+}
+
+// tesseractOutputs returns the file extensions tesseract produces for the job.
+func (job *Job) tesseractOutputs() (exts []string) {
+	for _, format := range job.OutputFormats() {
+		if f := outputFormats[format]; f.config != "" {
+			exts = append(exts, f.ext)
+		}
+	}
+	return
+}
+
+// makePdfA converts "output.pdf" to PDF/A-2b as "output-pdfa.pdf" using Ghostscript.
+func (job *Job) makePdfA(ctx context.Context) (err error) {
+	if job.HasFormat("pdfa") {
+		err = job.runsc(ctx, job.madeProgressHandler, "gs", "-dPDFA=2", "-dBATCH", "-dNOPAUSE", "-dNOOUTERSAVE", "--permit-file-read=/srgb.icc",
+			"-dPDFACompatibilityPolicy=1", "-sColorConversionStrategy=RGB", "-sDEVICE=pdfwrite",
+			"-sOutputFile=/var/rinse/output"+outputFormats["pdfa"].ext, "/pdfa_def.ps", "/var/rinse/output.pdf")
+	}
+	return
+}
+
+var hocrPageRx = regexp.MustCompile(`(id=['"][a-z]+_)(\d+)|(ppageno )(\d+)`)
+var altoPageRx = regexp.MustCompile(`(ID="page_)(\d+)|(PHYSICAL_IMG_NR=")(\d+)`)
+
+// offsetPages adds offset to the page numbers matched by rx.
+func offsetPages(rx *regexp.Regexp, b []byte, offset int) []byte {
+	return rx.ReplaceAllFunc(b, func(m []byte) []byte {
+		sm := rx.FindSubmatch(m)
+		prefix, num := sm[1], sm[2]
+		if prefix == nil {
+			prefix, num = sm[3], sm[4]
+		}
+		n, _ := strconv.Atoi(string(num))
+		return append(slices.Clone(prefix), strconv.Itoa(n+offset)...)
+	})
+}
+
+// mergeBetween joins the parts of each chunk found between the start and end
+// markers, keeping what comes before and after them in the first chunk.
+func mergeBetween(chunks [][]byte, start, end string, fn func(b []byte, n int) []byte) (merged []byte, err error) {
+	for n, b := range chunks {
+		i := bytes.Index(b, []byte(start))
+		j := bytes.LastIndex(b, []byte(end))
+		if i < 0 || j < i {
+			return nil, fmt.Errorf("missing %q in OCR output chunk %d", start, n)
+		}
+		i += len(start)
+		if n == 0 {
+			merged = append(merged, b[:i]...)
+		}
+		merged = append(merged, fn(b[i:j], n)...)
+		if n == len(chunks)-1 {
+			merged = append(merged, chunks[0][bytes.LastIndex(chunks[0], []byte(end)):]...)
+		}
+	}
+	return
+}
+
+// mergeTsv joins TSV chunks, keeping the first header and renumbering pages.
+func mergeTsv(chunks [][]byte, offsets []int) (merged []byte) {
+	for n, b := range chunks {
+		sc := bufio.NewScanner(bytes.NewReader(b))
+		sc.Buffer(nil, 1024*1024)
+		for first := true; sc.Scan(); first = false {
+			line := sc.Text()
+			if first {
+				if n == 0 {
+					merged = append(merged, line+"\n"...)
+				}
+				continue
+			}
+			if cols := strings.SplitN(line, "\t", 3); len(cols) == 3 {
+				if page, err := strconv.Atoi(cols[1]); err == nil {
+					line = cols[0] + "\t" + strconv.Itoa(page+offsets[n]) + "\t" + cols[2]
+				}
+			}
+			merged = append(merged, line+"\n"...)
+		}
+	}
+	return
+}
+
+// mergeOcrOutputs combines the non-PDF tesseract outputs of the chunks
+// "ocr-<n>" into "output", renumbering pages as needed.
+func (job *Job) mergeOcrOutputs(chunks [][]string) (err error) {
+	offsets := make([]int, len(chunks))
+	for n := 1; n < len(chunks); n++ {
+		offsets[n] = offsets[n-1] + len(chunks[n-1])
+	}
+	for _, ext := range job.tesseractOutputs() {
+		if ext != ".pdf" {
+			parts := make([][]byte, len(chunks))
+			for n := range chunks {
+				if parts[n], err = os.ReadFile(path.Join(job.Datadir, fmt.Sprintf("ocr-%d%s", n, ext))); err != nil {
+					return
+				}
+			}
+			var merged []byte
+			switch ext {
+			case ".hocr":
+				merged, err = mergeBetween(parts, "<body>", "</body>", func(b []byte, n int) []byte { return offsetPages(hocrPageRx, b, offsets[n]) })
+			case ".xml":
+				merged, err = mergeBetween(parts, "<Layout>", "</Layout>", func(b []byte, n int) []byte { return offsetPages(altoPageRx, b, offsets[n]) })
+			case ".tsv":
+				merged = mergeTsv(parts, offsets)
+			default:
+				merged = bytes.Join(parts, nil)
+			}
+			if err == nil {
+				err = os.WriteFile(path.Join(job.Datadir, "output"+ext), merged, 0644) // #nosec G306
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,56 @@
+package rinser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// HasChildren returns true if documents were unpacked from the job.
+func (job *Job) HasChildren() (yes bool) {
+	job.mu.Lock()
+	yes = job.Children > 0
+	job.mu.Unlock()
+	return
+}
+
+// IndentClass returns the CSS class used to indent unpacked documents below their parent.
+func (job *Job) IndentClass() string {
+	if job.Depth > 0 {
+		return fmt.Sprintf("ps-%d", min(5, job.Depth*2))
+	}
+	return ""
+}
+
+// CombinedName returns the file name of the ZIP file with all the rinsed documents.
+func (job *Job) CombinedName() string {
+	if job.IsArchive() {
+		return job.ResultName()
+	}
+	return strings.TrimSuffix(job.ResultName(), ".pdf") + ".zip"
+}
+
+// groupJobs orders jobs so that the jobs unpacked from a job follow it,
+// sorted by name. Jobs whose parent is not in the list keep their order.
+func groupJobs(jobs []*Job) (grouped []*Job) {
+	var addJob func(job *Job)
+	addJob = func(job *Job) {
+		grouped = append(grouped, job)
+		var children []*Job
+		for _, x := range jobs {
+			if x.Parent == job.UUID {
+				children = append(children, x)
+			}
+		}
+		slices.SortStableFunc(children, func(a, b *Job) int { return strings.Compare(a.Name, b.Name) })
+		for _, child := range children {
+			addJob(child)
+		}
+	}
+	for _, job := range jobs {
+		if !slices.ContainsFunc(jobs, func(x *Job) bool { return x.UUID == job.Parent }) {
+			addJob(job)
+		}
+	}
+	return
+}
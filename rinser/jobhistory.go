@@ -0,0 +1,81 @@
+package rinser
+
+import (
+	"errors"
+	"os/exec"
+	"slices"
+	"time"
+)
+
+// StageRecord records the time a job spent in a state.
+type StageRecord struct {
+	State    string    `json:"state" example:"Scanning"`
+	Started  time.Time `json:"started" example:"2024-01-01T12:00:00+00:00" format:"dateTime"`
+	Stopped  time.Time `json:"stopped,omitzero" example:"2024-01-01T12:00:30+00:00" format:"dateTime"`
+	Seconds  float64   `json:"seconds" example:"30.5"`     // time spent in the state
+	Runs     int       `json:"runs,omitempty" example:"1"` // sandboxes run
+	ExitCode int       `json:"exitcode" example:"0"`       // last non-zero sandbox exit code, -1 if it did not exit
+	Bytes    int64     `json:"bytes" example:"1048576"`    // change in disk usage
+	diskuse  int64     // disk usage when the state was entered
+}
+
+// recordStageLocked ends the current history record, if any,
+// and starts a new one unless the job is done.
+func (job *Job) recordStageLocked(state JobState, now time.Time) {
+	if n := len(job.History); n > 0 {
+		if rec := &job.History[n-1]; rec.Stopped.IsZero() {
+			rec.Stopped = now
+			rec.Seconds = now.Sub(rec.Started).Seconds()
+			rec.Bytes = job.Diskuse - rec.diskuse
+			metricStageSeconds.WithLabelValues(rec.State).Observe(rec.Seconds)
+		}
+	}
+	if state != JobFinished && state != JobFailed {
+		job.History = append(job.History, StageRecord{
+			State:   jobStateText(state),
+			Started: now,
+			diskuse: job.Diskuse,
+		})
+	}
+}
+
+// interruptStageLocked ends the current history record
+// of a job that was running when the service stopped.
+func (job *Job) interruptStageLocked(now time.Time) {
+	if n := len(job.History); n > 0 {
+		if rec := &job.History[n-1]; rec.Stopped.IsZero() {
+			rec.Stopped = now
+			rec.Seconds = now.Sub(rec.Started).Seconds()
+			rec.ExitCode = -1
+		}
+	}
+}
+
+// recordExit records the outcome of a sandbox run in the current
+// history record, and returns the sandbox exit code.
+func (job *Job) recordExit(err error) (code int) {
+	var exiterr *exec.ExitError
+	if errors.As(err, &exiterr) {
+		code = exiterr.ExitCode()
+	} else if err != nil {
+		code = -1
+	}
+	observeRunscExit(code)
+	job.mu.Lock()
+	if n := len(job.History); n > 0 && job.History[n-1].Stopped.IsZero() {
+		job.History[n-1].Runs++
+		if code != 0 {
+			job.History[n-1].ExitCode = code
+		}
+	}
+	job.mu.Unlock()
+	return
+}
+
+// GetHistory returns a copy of the job's history.
+func (job *Job) GetHistory() (history []StageRecord) {
+	job.mu.Lock()
+	history = slices.Clone(job.History)
+	job.mu.Unlock()
+	return
+}
@@ -0,0 +1,91 @@
+package rinser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrIllegalPageRange = errors.New("illegal page range")
+var ErrNoPagesSelected = errors.New("no pages selected")
+
+// pageRange is a range of page numbers, starting at 1.
+// A last page of zero means the range runs to the end of the document.
+type pageRange struct {
+	first, last int
+}
+
+func parsePageRanges(s string) (ranges []pageRange, err error) {
+	for part := range strings.SplitSeq(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			var pr pageRange
+			firstText, lastText, isRange := strings.Cut(part, "-")
+			if pr.first, err = strconv.Atoi(strings.TrimSpace(firstText)); err == nil {
+				pr.last = pr.first
+				if isRange {
+					pr.last = 0
+					if lastText = strings.TrimSpace(lastText); lastText != "" {
+						pr.last, err = strconv.Atoi(lastText)
+					}
+				}
+			}
+			if err != nil || pr.first < 1 || (pr.last != 0 && pr.last < pr.first) {
+				return nil, fmt.Errorf("%w: %q", ErrIllegalPageRange, part)
+			}
+			ranges = append(ranges, pr)
+		}
+	}
+	return
+}
+
+// ParsePageRanges checks a page selection such as "1-5,10,20-"
+// and returns it in normal form. The empty string selects all pages.
+func ParsePageRanges(s string) (normal string, err error) {
+	var ranges []pageRange
+	if ranges, err = parsePageRanges(s); err == nil {
+		var parts []string
+		for _, pr := range ranges {
+			switch pr.last {
+			case pr.first:
+				parts = append(parts, strconv.Itoa(pr.first))
+			case 0:
+				parts = append(parts, strconv.Itoa(pr.first)+"-")
+			default:
+				parts = append(parts, strconv.Itoa(pr.first)+"-"+strconv.Itoa(pr.last))
+			}
+		}
+		normal = strings.Join(parts, ",")
+	}
+	return
+}
+
+// selectPages returns the sorted page numbers selected by pageRanges
+// in a document with numPages pages.
+func selectPages(pageRanges string, numPages int) (pages []int, err error) {
+	var ranges []pageRange
+	if ranges, err = parsePageRanges(pageRanges); err == nil {
+		if len(ranges) == 0 {
+			ranges = append(ranges, pageRange{first: 1})
+		}
+		selected := make([]bool, numPages+1)
+		for _, pr := range ranges {
+			last := numPages
+			if pr.last != 0 {
+				last = min(last, pr.last)
+			}
+			for page := pr.first; page <= last; page++ {
+				selected[page] = true
+			}
+		}
+		for page, ok := range selected {
+			if ok {
+				pages = append(pages, page)
+			}
+		}
+		if len(pages) == 0 {
+			err = fmt.Errorf("%w: %q of %d pages", ErrNoPagesSelected, pageRanges, numPages)
+		}
+	}
+	return
+}
@@ -19,6 +19,10 @@ import (
 	"strings"
 	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 )
 
 var ErrImageSeenTwice = errors.New("image file seen twice")
@@ -26,11 +30,12 @@ var ErrImageSeenTwice = errors.New("image file seen twice")
 func (job *Job) watchProgress(ctx context.Context) {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
+	stoppedCh := job.StoppedCh()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-job.StoppedCh:
+		case <-stoppedCh:
 			return
 		case <-ticker.C:
 			job.mu.Lock()
@@ -55,26 +60,34 @@ func (job *Job) process(ctx context.Context) {
 	job.mu.Unlock()
 	defer job.processDone()
 
-	var err error
-	if err = job.runDownload(ctx); err == nil {
-		var docName, wrkName string
-		if docName, wrkName, err = job.runDocumentName(); err == nil {
-			if err = job.runExtractMeta(ctx, docName); err == nil {
-				if err = job.renameDoc(docName, wrkName); err == nil {
-					if err = job.runDetectLanguage(ctx, wrkName); err == nil {
-						if err = job.runDocToPdf(ctx, wrkName); err == nil {
-							if err = job.runPdfToImages(ctx); err == nil {
-								if err = job.runTesseract(ctx); err == nil {
-									if err = job.jobEnding(ctx); err == nil {
-										if err = job.transition(ctx, JobEnding, JobFinished); err == nil {
-											return
-										}
-									}
-								}
-							}
-						}
-					}
-				}
+	ctx, span := job.startSpan(ctx, "rinse.job")
+	stages, err := job.Rinse.pipelineStages()
+	defer func() { endSpan(span, err) }()
+	if err == nil {
+		stages, err = job.resumeStages(stages)
+	}
+	if err == nil {
+		state := JobStarting
+		for _, stage := range stages {
+			if err = job.transition(ctx, state, stage.state); err != nil {
+				break
+			}
+			state = stage.state
+			stageCtx, stageSpan := job.startSpan(ctx, "rinse.stage."+stage.id)
+			err = stage.Run(stageCtx, job)
+			if errors.Is(err, ErrPipelineDone) {
+				err = nil
+				stageSpan.End()
+				break
+			}
+			endSpan(stageSpan, err)
+			if err != nil {
+				break
+			}
+		}
+		if err == nil {
+			if err = job.transition(ctx, state, JobFinished); err == nil {
+				return
 			}
 		}
 	}
@@ -88,22 +101,36 @@ func (job *Job) process(ctx context.Context) {
 		job.Error = err
 	}
 	job.state = JobFailed
+	errstate := job.errstate
 	job.mu.Unlock()
-	job.Rinse.Jaws.Dirty(uiJobStatus{job})
+	metricFailures.WithLabelValues(jobStateText(errstate)).Inc()
+	job.scheduleRetry(err)
+	job.save()
+	job.Rinse.Jaws.Dirty(job, uiJobStatus{job})
 }
 
 func (job *Job) processDone() {
+	job.refreshDiskuse()
 	job.mu.Lock()
 	job.stopped = time.Now()
+	job.recordStageLocked(job.state, job.stopped)
 	job.Done = true
 	job.cancelFn = nil
 	closed := job.closed
-	close(job.StoppedCh)
+	close(job.stoppedCh)
 	job.mu.Unlock()
 	job.Rinse.Info("job stopped", "job", job.Name, "email", job.Email, "workdir", job.Workdir)
 	if closed {
 		job.removeAll()
+	} else {
+		job.save()
 	}
+	event := JobEventFinished
+	if job.State() == JobFailed {
+		event = JobEventFailed
+	}
+	job.publishEvent(event)
+	job.notifyWebhooks()
 }
 
 var ErrIllegalURLScheme = errors.New("illegal URL scheme")
@@ -115,6 +142,12 @@ func hasHTTPScheme(s string) bool {
 	return strings.HasPrefix(s, "http:") || strings.HasPrefix(s, "https:")
 }
 
+// isURL returns true if the job document is to be downloaded.
+// Documents unpacked from archives are never downloaded.
+func (job *Job) isURL() bool {
+	return job.Parent == uuid.Nil && hasHTTPScheme(job.Name)
+}
+
 func (job *Job) limitDocumentSize(resp *http.Response) (src io.Reader, maxUploadSize int64, err error) {
 	src = resp.Body
 	if maxUploadSize = job.MaxUploadSize(); maxUploadSize > 0 {
@@ -127,52 +160,63 @@ func (job *Job) limitDocumentSize(resp *http.Response) (src io.Reader, maxUpload
 }
 
 func (job *Job) runDownload(ctx context.Context) (err error) {
-	if err = job.transition(ctx, JobStarting, JobDownload); err == nil {
-		if hasHTTPScheme(job.Name) {
-			var req *http.Request
-			if req, err = http.NewRequestWithContext(ctx, http.MethodGet, job.Name, nil); err == nil {
-				var resp *http.Response
-				if resp, err = job.Rinse.getClient().Do(req); err == nil { // #nosec G107
-					if resp.StatusCode == http.StatusOK {
-						srcName := resp.Request.URL.Path
-						if cd := resp.Header.Get("Content-Disposition"); cd != "" {
-							if _, params, e := mime.ParseMediaType(cd); e == nil {
-								if s, ok := params["filename"]; ok {
-									srcName = s
-								}
+	if err = job.download(ctx); err == nil {
+		_, err = job.DocumentFile()
+	}
+	return
+}
+
+func (job *Job) download(ctx context.Context) (err error) {
+	if job.isURL() {
+		var span trace.Span
+		ctx, span = job.startSpan(ctx, "download", trace.WithSpanKind(trace.SpanKindClient))
+		defer func() { endSpan(span, err) }()
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, job.Name, nil); err == nil {
+			injectTrace(ctx, req)
+			var resp *http.Response
+			if resp, err = job.Rinse.getClient().Do(req); err == nil { // #nosec G107
+				if resp.StatusCode == http.StatusOK {
+					srcName := resp.Request.URL.Path
+					if cd := resp.Header.Get("Content-Disposition"); cd != "" {
+						if _, params, e := mime.ParseMediaType(cd); e == nil {
+							if s, ok := params["filename"]; ok {
+								srcName = s
 							}
 						}
-						srcName = path.Base(srcName)
-						if filepath.Ext(srcName) == "" {
-							if ct := resp.Header.Get("Content-Type"); ct != "" {
-								if mediatype, _, e := mime.ParseMediaType(ct); e == nil {
-									if exts, e := mime.ExtensionsByType(mediatype); e == nil {
-										srcName += exts[0]
-									}
+					}
+					srcName = path.Base(srcName)
+					if filepath.Ext(srcName) == "" {
+						if ct := resp.Header.Get("Content-Type"); ct != "" {
+							if mediatype, _, e := mime.ParseMediaType(ct); e == nil {
+								if exts, e := mime.ExtensionsByType(mediatype); e == nil {
+									srcName += exts[0]
 								}
 							}
 						}
+					}
 
-						var srcFile io.Reader
-						var maxUploadSize int64
-						if srcFile, maxUploadSize, err = job.limitDocumentSize(resp); err == nil {
-							var of *os.File
-							if of, err = os.Create(path.Join(job.Datadir, srcName)); err == nil /* #nosec G304 */ {
-								defer of.Close()
-								var written int64
-								if written, err = io.Copy(of, srcFile); err == nil {
-									if maxUploadSize < 1 || written <= maxUploadSize {
-										if err = of.Close(); err == nil {
-											return
-										}
+					var srcFile io.Reader
+					var maxUploadSize int64
+					if srcFile, maxUploadSize, err = job.limitDocumentSize(resp); err == nil {
+						var of *os.File
+						if of, err = os.Create(path.Join(job.Datadir, srcName)); err == nil /* #nosec G304 */ {
+							defer of.Close()
+							var written int64
+							written, err = io.Copy(of, srcFile)
+							metricBytesIn.Add(float64(written))
+							if err == nil {
+								if maxUploadSize < 1 || written <= maxUploadSize {
+									if err = of.Close(); err == nil {
+										return
 									}
-									err = ErrDocumentTooLarge
 								}
+								err = ErrDocumentTooLarge
 							}
 						}
-					} else {
-						err = errors.New(resp.Status)
 					}
+				} else {
+					err = downloadStatusError{code: resp.StatusCode, status: resp.Status}
 				}
 			}
 		}
@@ -187,7 +231,7 @@ func mustHaveDocument(s string, n int64) error {
 	return nil
 }
 
-func (job *Job) runDocumentName() (docName, wrkName string, err error) {
+func (job *Job) runDocumentName() (docName string, err error) {
 	var docSize int64
 	err = filepath.WalkDir(job.Datadir, func(fpath string, d fs.DirEntry, err error) error {
 		if err == nil {
@@ -211,14 +255,21 @@ func (job *Job) runDocumentName() (docName, wrkName string, err error) {
 
 	if err == nil {
 		if err = mustHaveDocument(docName, docSize); err == nil {
+			var mimeType, workExt, inputHash string
+			if mimeType, workExt, err = job.checkDocumentType(docName); err == nil {
+				inputHash, err = fileSHA256(path.Join(job.Datadir, docName))
+			}
 			ext := filepath.Ext(docName)
 
 			job.mu.Lock()
-			job.docName = docName
-			job.PdfName = strings.ReplaceAll(strings.TrimSuffix(docName, ext)+"-"+strings.TrimPrefix(ext, ".")+"-rinsed.pdf", "\"", "")
+			job.MimeType = mimeType
+			if err == nil {
+				job.InputSHA256 = inputHash
+				job.docName = docName
+				job.workExt = workExt
+				job.PdfName = strings.ReplaceAll(strings.TrimSuffix(docName, ext)+"-"+strings.TrimPrefix(ext, ".")+"-rinsed.pdf", "\"", "")
+			}
 			job.mu.Unlock()
-
-			wrkName = "input" + strings.ToLower(ext)
 		}
 	}
 	return
@@ -233,8 +284,54 @@ func (job *Job) renameDoc(docName, wrkName string) (err error) {
 	return
 }
 
-func (job *Job) runExtractMeta(ctx context.Context, docName string) (err error) {
-	if err = job.transition(ctx, JobDownload, JobExtractMeta); err == nil {
+// workName returns the working file name for the document, which keeps
+// the document extension unless the document was converted while unpacking.
+func (job *Job) workName(docName string) string {
+	job.mu.Lock()
+	ext := job.workExt
+	job.mu.Unlock()
+	if ext == "" {
+		ext = filepath.Ext(docName)
+	}
+	return "input" + strings.ToLower(ext)
+}
+
+// DocumentFile returns the current file name of the document in the
+// job data directory, locating the document if not yet done.
+func (job *Job) DocumentFile() (fn string, err error) {
+	if fn = job.DocumentName(); fn == "" {
+		fn, err = job.runDocumentName()
+	}
+	if err == nil {
+		if wrkName := job.workName(fn); fileExists(path.Join(job.Datadir, wrkName)) {
+			fn = wrkName
+		}
+	}
+	return
+}
+
+// workFile returns the working file name of the document, renaming
+// the original document to it and making it read-only if needed.
+func (job *Job) workFile() (wrkName string, err error) {
+	var fn string
+	if fn, err = job.DocumentFile(); err == nil {
+		if wrkName = job.workName(job.DocumentName()); fn != wrkName {
+			err = job.renameDoc(fn, wrkName)
+		}
+	}
+	return
+}
+
+func (job *Job) runExtractMeta(ctx context.Context) (err error) {
+	if fileExists(job.MetaPath()) {
+		// already extracted while unpacking
+		if job.GetRisk() == nil {
+			err = job.assessRisk()
+		}
+		return
+	}
+	var docName string
+	if docName, err = job.DocumentFile(); err == nil {
 		var buf bytes.Buffer
 		stdouthandler := func(s string, isout bool) (err error) {
 			if isout {
@@ -248,7 +345,9 @@ func (job *Job) runExtractMeta(ctx context.Context, docName string) (err error)
 			if err = json.Unmarshal(buf.Bytes(), &obj); err == nil {
 				var b []byte
 				if b, err = json.MarshalIndent(obj, "", "  "); err == nil {
-					err = os.WriteFile(job.MetaPath(), b, 0644) // #nosec G306
+					if err = os.WriteFile(job.MetaPath(), b, 0644); err == nil /* #nosec G306 */ {
+						err = job.runRiskScan(ctx, docName)
+					}
 				}
 			}
 		}
@@ -256,10 +355,29 @@ func (job *Job) runExtractMeta(ctx context.Context, docName string) (err error)
 	return
 }
 
+// runRiskScan assesses the risk of the document and the documents embedded in it.
+// The recursive Tika output holds the text of the unrinsed document, so it is only
+// kept in memory and never served.
+func (job *Job) runRiskScan(ctx context.Context, docName string) (err error) {
+	var buf bytes.Buffer
+	stdouthandler := func(s string, isout bool) (err error) {
+		if isout {
+			job.madeProgress()
+			buf.WriteString(s)
+		}
+		return
+	}
+	if err = job.runsc(ctx, stdouthandler, "java", "-jar", "/usr/local/bin/tika.jar", "--config=/tika-config.xml", "-J", "/var/rinse/"+docName); err == nil {
+		err = job.setRisk(buf.Bytes())
+	}
+	return
+}
+
 var detectLanguageRx = regexp.MustCompile(`DetectedLanguage\[(\w+):(\d\.\d+)\]`)
 
-func (job *Job) runDetectLanguage(ctx context.Context, fn string) (err error) {
-	if err = job.transition(ctx, JobExtractMeta, JobDetectLanguage); err == nil {
+func (job *Job) runDetectLanguage(ctx context.Context) (err error) {
+	var fn string
+	if fn, err = job.workFile(); err == nil {
 		if job.Lang() == "" {
 			langs := map[string]float64{}
 			stdouthandler := func(s string, isout bool) (err error) {
@@ -305,8 +423,9 @@ func (job *Job) waitForDocToPdf(ctx context.Context, fn string) (err error) {
 	return
 }
 
-func (job *Job) runDocToPdf(ctx context.Context, fn string) (err error) {
-	if err = job.transition(ctx, JobDetectLanguage, JobDocToPdf); err == nil {
+func (job *Job) runDocToPdf(ctx context.Context) (err error) {
+	var fn string
+	if fn, err = job.workFile(); err == nil {
 		if err = job.waitForDocToPdf(ctx, fn); err == nil {
 			if err = scrub(path.Join(job.Datadir, ".cache")); err == nil {
 				err = scrub(path.Join(job.Datadir, ".config"))
@@ -325,23 +444,30 @@ func (job *Job) waitForPdfToImages(ctx context.Context) (err error) {
 			job.refreshDiskuse()
 		}
 	}()
-	return job.runsc(ctx, nil, "pdftoppm", "-png", "-cropbox", "/var/rinse/input.pdf", "/var/rinse/output")
+	var args []string
+	if args, err = job.renderArgs(ctx); err == nil {
+		err = job.runsc(ctx, nil, args...)
+	}
+	return
 }
 
-func (job *Job) makeOutputTxt() (err error) {
+func (job *Job) pageFiles() (pages []string) {
+	job.mu.Lock()
+	for fn := range job.imgfiles {
+		pages = append(pages, fn)
+	}
+	job.mu.Unlock()
+	sort.Strings(pages)
+	return
+}
+
+func (job *Job) writePageList(fn string, pages []string) (err error) {
 	var f *os.File
-	fpath := filepath.Clean(path.Join(job.Datadir, "output.txt"))
+	fpath := filepath.Clean(path.Join(job.Datadir, fn))
 	if f, err = os.OpenFile(fpath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644); err == nil /* #nosec G302 */ {
 		defer f.Close()
-		job.mu.Lock()
-		var outputFiles []string
-		for fn := range job.imgfiles {
-			outputFiles = append(outputFiles, fn)
-		}
-		job.mu.Unlock()
-		sort.Strings(outputFiles)
-		for _, fn := range outputFiles {
-			if _, err = fmt.Fprintf(f, "/var/rinse/%s\n", fn); err != nil {
+		for _, pagefn := range pages {
+			if _, err = fmt.Fprintf(f, "/var/rinse/%s\n", pagefn); err != nil {
 				return
 			}
 		}
@@ -351,76 +477,173 @@ func (job *Job) makeOutputTxt() (err error) {
 }
 
 func (job *Job) runPdfToImages(ctx context.Context) (err error) {
-	if err = job.transition(ctx, JobDocToPdf, JobPdfToImages); err == nil {
+	if _, err = job.workFile(); err == nil {
 		if err = job.waitForPdfToImages(ctx); err == nil {
 			if err = scrub(path.Join(job.Datadir, "input.pdf")); err == nil {
 				job.refreshDiskuse()
-				err = job.makeOutputTxt()
+				job.mu.Lock()
+				job.Pages = len(job.imgfiles)
+				job.mu.Unlock()
 			}
 		}
 	}
 	return
 }
 
+func (job *Job) tesseractHandler(s string, isout bool) error {
+	if !isout {
+		defer job.Rinse.Jaws.Dirty(uiJobStatus{job})
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		for fn, seen := range job.imgfiles {
+			if strings.Contains(s, fn) {
+				if seen {
+					if strings.Contains(s, "file not found") {
+						return errors.New(s)
+					}
+					return ErrImageSeenTwice
+				}
+				job.imgfiles[fn] = true
+				job.progress = time.Now()
+				job.Rinse.publishEvent(job, job.eventLocked(JobEventScanned))
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (job *Job) tesseractArgs(listfn, outbase string) (args []string) {
+	args = []string{
+		"tesseract",
+	}
+	if s := job.Lang(); s != "" {
+		args = append(args, "-l", s)
+	}
+	args = append(args, "/var/rinse/"+listfn, "/var/rinse/"+outbase)
+	args = append(args, job.tesseractConfigs()...)
+	return
+}
+
+// minPagesPerChunk is the fewest pages worth starting a separate OCR sandbox for.
+const minPagesPerChunk = 4
+
+// ocrChunks splits the pages into at most maxChunks contiguous chunks.
+func ocrChunks(pages []string, maxChunks int) (chunks [][]string) {
+	n := max(1, min(maxChunks, len(pages)/minPagesPerChunk))
+	for i := range n {
+		chunks = append(chunks, pages[i*len(pages)/n:(i+1)*len(pages)/n])
+	}
+	return
+}
+
 func (job *Job) runTesseract(ctx context.Context) (err error) {
-	if err = job.transition(ctx, JobPdfToImages, JobTesseract); err == nil {
-		stdouthandler := func(s string, isout bool) error {
-			if !isout {
-				defer job.Rinse.Jaws.Dirty(uiJobStatus{job})
-				job.mu.Lock()
-				defer job.mu.Unlock()
-				for fn, seen := range job.imgfiles {
-					if strings.Contains(s, fn) {
-						if seen {
-							if strings.Contains(s, "file not found") {
-								return errors.New(s)
-							}
-							return ErrImageSeenTwice
-						}
-						job.imgfiles[fn] = true
-						job.progress = time.Now()
-						break
+	pages := job.pageFiles()
+	chunks := ocrChunks(pages, job.Rinse.OcrCPUs())
+	if len(chunks) < 2 {
+		if err = job.writePageList("pages.txt", pages); err == nil {
+			if err = job.Rinse.acquireOcrCPU(ctx); err == nil {
+				err = job.runsc(ctx, job.tesseractHandler, job.tesseractArgs("pages.txt", "output")...)
+				job.Rinse.releaseOcrCPU()
+				if err == nil {
+					if err = job.embedStampedPages(ctx); err == nil {
+						err = job.makePdfA(ctx)
 					}
 				}
 			}
-			return nil
 		}
-		args := []string{
-			"tesseract",
+		return
+	}
+
+	eg, egctx := errgroup.WithContext(ctx)
+	partfiles := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		partfiles[i] = fmt.Sprintf("/var/rinse/ocr-%d.pdf", i)
+		eg.Go(func() (err error) {
+			return job.runTesseractChunk(egctx, i, chunk)
+		})
+	}
+	if err = eg.Wait(); err == nil {
+		args := append([]string{"pdfunite"}, partfiles...)
+		if err = job.runsc(ctx, job.madeProgressHandler, append(args, "/var/rinse/output.pdf")...); err == nil {
+			if err = job.mergeOcrOutputs(chunks); err == nil {
+				for i := range chunks {
+					for _, ext := range job.tesseractOutputs() {
+						if err = scrub(path.Join(job.Datadir, fmt.Sprintf("ocr-%d%s", i, ext))); err != nil {
+							return
+						}
+					}
+				}
+				if err = job.embedStampedPages(ctx); err == nil {
+					err = job.makePdfA(ctx)
+				}
+			}
 		}
-		if s := job.Lang(); s != "" {
-			args = append(args, "-l", s)
+	}
+	return
+}
+
+// runTesseractChunk OCRs a subset of the pages in a sandbox of its own,
+// producing "ocr-<n>.pdf".
+func (job *Job) runTesseractChunk(ctx context.Context, n int, pages []string) (err error) {
+	name := fmt.Sprintf("ocr-%d", n)
+	listfn := fmt.Sprintf("pages-%d.txt", n)
+	bundleDir := path.Join(job.Workdir, name)
+	if err = os.Mkdir(bundleDir, 0777); err == nil /* #nosec G301 */ {
+		defer os.RemoveAll(bundleDir)
+		if err = job.writePageList(listfn, pages); err == nil {
+			if err = job.Rinse.acquireOcrCPU(ctx); err == nil {
+				defer job.Rinse.releaseOcrCPU()
+				id := job.UUID.String() + "-" + name
+				env := []string{"OMP_THREAD_LIMIT=1"}
+				err = job.runscBundle(ctx, bundleDir, id, env, job.tesseractHandler, job.tesseractArgs(listfn, name)...)
+			}
 		}
-		args = append(args, "/var/rinse/output.txt", "/var/rinse/output", "pdf")
-		err = job.runsc(ctx, stdouthandler, args...)
 	}
 	return
 }
 
 func (job *Job) jobEnding(ctx context.Context) (err error) {
-	if err = job.transition(ctx, JobTesseract, JobEnding); err == nil {
-		if err = os.Rename(path.Join(job.Datadir, "output.pdf"), path.Join(job.Datadir, job.ResultName())); err == nil {
-			var diskuse int64
-			err = filepath.WalkDir(job.Datadir, func(fpath string, d fs.DirEntry, err error) error {
-				if err == nil {
-					if d.Type().IsRegular() {
-						switch filepath.Ext(d.Name()) {
-						case ".png", ".pdf", ".json":
-							if fi, e := d.Info(); e == nil {
-								diskuse += fi.Size()
-							}
-						default:
-							_ = scrub(fpath)
+	outputs := map[string]bool{}
+	for _, format := range job.OutputFormats() {
+		name := job.OutputName(format)
+		outputs[name] = true
+		if err == nil {
+			err = os.Rename(path.Join(job.Datadir, "output"+outputFormats[format].ext), path.Join(job.Datadir, name))
+		}
+	}
+	if err == nil && job.Rinse.signer != nil {
+		outputs[job.SignatureName()] = true
+		err = job.signOutput()
+	}
+	if err == nil {
+		var diskuse int64
+		err = filepath.WalkDir(job.Datadir, func(fpath string, d fs.DirEntry, err error) error {
+			if err == nil {
+				if d.Type().IsRegular() {
+					switch ext := filepath.Ext(d.Name()); {
+					case outputs[d.Name()], ext == ".png", ext == ".pdf", ext == ".json":
+						if fi, e := d.Info(); e == nil {
+							diskuse += fi.Size()
 						}
+					default:
+						_ = scrub(fpath)
 					}
 				}
-				return nil
-			})
-			job.mu.Lock()
-			job.Diskuse = diskuse
-			job.mu.Unlock()
-			job.Rinse.Jaws.Dirty(job, uiJobStatus{job})
+			}
+			return nil
+		})
+		if err == nil {
+			if err = job.hashOutputs(); err == nil {
+				if e := job.storeCache(); e != nil {
+					job.Rinse.Error("storeCache", "job", job.Name, "err", e)
+				}
+			}
 		}
+		job.mu.Lock()
+		job.Diskuse = diskuse
+		job.mu.Unlock()
+		job.Rinse.Jaws.Dirty(job, uiJobStatus{job})
 	}
 	return
 }
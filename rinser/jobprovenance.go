@@ -0,0 +1,213 @@
+package rinser
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// toolPackages lists the worker rootfs packages whose versions are given in manifests.
+var toolPackages = []string{"libreoffice", "tesseract-ocr", "poppler-utils", "ghostscript", "libarchive-tools", "openjdk11-jre-headless"}
+
+// ManifestFile is a document named in a Manifest.
+type ManifestFile struct {
+	Name     string `json:"name" example:"example.docx"`
+	Format   string `json:"format,omitempty" example:"pdf"`
+	MimeType string `json:"mimetype,omitempty" example:"application/vnd.openxmlformats-officedocument.wordprocessingml.document"`
+	SHA256   string `json:"sha256" example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"`
+}
+
+// ManifestSettings are the settings a job was rinsed with.
+type ManifestSettings struct {
+	Formats    []string `json:"formats" example:"pdf,txt"`
+	Dpi        int      `json:"dpi" example:"150"`
+	ColorMode  string   `json:"colormode" example:"color"`
+	PageRanges string   `json:"pageranges,omitempty" example:"1-5,10,20-"`
+	Stamped    bool     `json:"stamped,omitempty" example:"false"`
+	MaxSizeMB  int      `json:"maxsizemb" example:"2048"`
+	MaxTimeSec int      `json:"maxtimesec" example:"86400"`
+	TimeoutSec int      `json:"timeoutsec" example:"60"`
+}
+
+// Manifest records the provenance of the documents a job produced.
+type Manifest struct {
+	UUID     uuid.UUID         `json:"uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"`
+	Parent   uuid.UUID         `json:"parent,omitzero" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"`
+	Created  time.Time         `json:"created" example:"2024-01-01T12:00:00+00:00" format:"dateTime"`
+	Stopped  time.Time         `json:"stopped" example:"2024-01-01T12:01:00+00:00" format:"dateTime"`
+	Input    ManifestFile      `json:"input"`
+	Outputs  []ManifestFile    `json:"outputs,omitempty"`
+	Language string            `json:"lang,omitempty" example:"eng"`
+	Pages    int               `json:"pages,omitempty" example:"1"`
+	Cached   bool              `json:"cached,omitempty" example:"false"`
+	Settings ManifestSettings  `json:"settings"`
+	History  []StageRecord     `json:"history,omitempty"`
+	Tools    map[string]string `json:"tools,omitempty"`
+}
+
+func fileSHA256(fpath string) (s string, err error) {
+	var f *os.File
+	if f, err = os.Open(fpath); err == nil /* #nosec G304 */ {
+		defer f.Close()
+		h := sha256.New()
+		if _, err = io.Copy(h, f); err == nil {
+			s = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return
+}
+
+// hashOutputs records the SHA-256 of each output of the job.
+func (job *Job) hashOutputs() (err error) {
+	hashes := map[string]string{}
+	for _, format := range job.OutputFormats() {
+		var s string
+		if s, err = fileSHA256(path.Join(job.Datadir, job.OutputName(format))); err != nil {
+			return
+		}
+		hashes[format] = s
+	}
+	job.mu.Lock()
+	job.outputHashes = hashes
+	job.OutputSHA256 = hashes["pdf"]
+	job.mu.Unlock()
+	return
+}
+
+// apkVersions returns the versions of the named packages from an Alpine package database.
+func apkVersions(fpath string, names []string) (versions map[string]string) {
+	versions = map[string]string{}
+	if f, err := os.Open(fpath); err == nil /* #nosec G304 */ {
+		defer f.Close()
+		var pkg string
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := sc.Text()
+			if s, ok := strings.CutPrefix(line, "P:"); ok {
+				pkg = s
+			} else if s, ok := strings.CutPrefix(line, "V:"); ok {
+				for _, name := range names {
+					if name == pkg {
+						versions[pkg] = s
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// jarVersion returns the Implementation-Version from the manifest of a JAR file.
+func jarVersion(fpath string) (s string) {
+	if zr, err := zip.OpenReader(fpath); err == nil {
+		defer zr.Close()
+		if rc, err := zr.Open("META-INF/MANIFEST.MF"); err == nil {
+			defer rc.Close()
+			sc := bufio.NewScanner(rc)
+			for sc.Scan() {
+				if v, ok := strings.CutPrefix(sc.Text(), "Implementation-Version:"); ok {
+					return strings.TrimSpace(v)
+				}
+			}
+		}
+	}
+	return
+}
+
+func readToolVersions(rootDir string) (versions map[string]string) {
+	versions = apkVersions(path.Join(rootDir, "lib/apk/db/installed"), toolPackages)
+	if b, err := os.ReadFile(path.Join(rootDir, "etc/alpine-release")); err == nil /* #nosec G304 */ {
+		versions["alpine"] = strings.TrimSpace(string(b))
+	}
+	if s := jarVersion(path.Join(rootDir, "usr/local/bin/tika.jar")); s != "" {
+		versions["tika"] = s
+	}
+	versions["rinse"] = PkgVersion
+	return
+}
+
+// ToolVersions returns the versions of the tools in the worker rootfs.
+// The returned map must not be modified.
+func (rns *Rinse) ToolVersions() (versions map[string]string) {
+	rns.mu.Lock()
+	versions = rns.toolVersions
+	rns.mu.Unlock()
+	if versions == nil {
+		versions = readToolVersions(rns.RootDir)
+		rns.mu.Lock()
+		rns.toolVersions = versions
+		rns.mu.Unlock()
+	}
+	return
+}
+
+// HasManifest returns true if the job has finished and so has a provenance manifest.
+func (job *Job) HasManifest() bool {
+	return job.State() == JobFinished
+}
+
+// ManifestName returns the file name for the provenance manifest.
+func (job *Job) ManifestName() string {
+	name := job.ResultName()
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "-manifest.json"
+}
+
+// Manifest returns the provenance manifest of the job.
+func (job *Job) Manifest() (m Manifest) {
+	dpi, colorMode, _ := job.rendering()
+	tools := job.Rinse.ToolVersions()
+	history := job.GetHistory()
+	stamped := job.stamped()
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	m = Manifest{
+		UUID:    job.UUID,
+		Parent:  job.Parent,
+		Created: job.Created,
+		Stopped: job.stopped,
+		Input: ManifestFile{
+			Name:     job.docName,
+			MimeType: job.MimeType,
+			SHA256:   job.InputSHA256,
+		},
+		Language: job.Language,
+		Pages:    job.Pages,
+		Cached:   job.Cached,
+		Settings: ManifestSettings{
+			Formats:    append([]string{"pdf"}, job.Formats...),
+			Dpi:        dpi,
+			ColorMode:  colorMode,
+			PageRanges: job.PageRanges,
+			Stamped:    stamped,
+			MaxSizeMB:  job.MaxSizeMB,
+			MaxTimeSec: job.MaxTimeSec,
+			TimeoutSec: job.TimeoutSec,
+		},
+		History: history,
+		Tools:   tools,
+	}
+	for _, format := range job.OutputFormats() {
+		if s, ok := job.outputHashes[format]; ok {
+			name := job.PdfName
+			if format != "pdf" {
+				name = strings.TrimSuffix(name, ".pdf") + outputFormats[format].ext
+			}
+			m.Outputs = append(m.Outputs, ManifestFile{
+				Name:     name,
+				Format:   format,
+				MimeType: outputFormats[format].mime,
+				SHA256:   s,
+			})
+		}
+	}
+	return
+}
@@ -0,0 +1,169 @@
+package rinser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrIllegalDpi = errors.New("illegal DPI")
+var ErrIllegalColorMode = errors.New("illegal colour mode")
+
+const (
+	MinDpi = 36
+	MaxDpi = 1200
+)
+
+// ColorModes lists the page rendering colour modes.
+var ColorModes = []string{"color", "gray", "mono"}
+
+// CheckRendering returns an error if dpi or colorMode are not valid.
+// Zero and the empty string are valid and mean the admin default.
+func CheckRendering(dpi int, colorMode string) error {
+	if dpi != 0 && (dpi < MinDpi || dpi > MaxDpi) {
+		return fmt.Errorf("%w: %d is not between %d and %d", ErrIllegalDpi, dpi, MinDpi, MaxDpi)
+	}
+	if colorMode != "" && !slices.Contains(ColorModes, colorMode) {
+		return fmt.Errorf("%w: %q", ErrIllegalColorMode, colorMode)
+	}
+	return nil
+}
+
+// parseRendering parses the DPI and colour mode query or form values.
+func parseRendering(dpiText, colorMode string) (dpi int, mode string, err error) {
+	if dpiText = strings.TrimSpace(dpiText); dpiText != "" {
+		if dpi, err = strconv.Atoi(dpiText); err != nil {
+			err = fmt.Errorf("%w: %q", ErrIllegalDpi, dpiText)
+		}
+	}
+	if err == nil {
+		mode = strings.ToLower(strings.TrimSpace(colorMode))
+		err = CheckRendering(dpi, mode)
+	}
+	return
+}
+
+// Rendering returns the admin defaults for page rendering.
+func (rns *Rinse) Rendering() (dpi int, colorMode string, maxMegapixels int) {
+	rns.mu.Lock()
+	dpi = rns.dpi
+	colorMode = rns.colorMode
+	maxMegapixels = rns.maxMegapixels
+	rns.mu.Unlock()
+	return
+}
+
+// rendering returns the DPI and colour mode to render the job pages with.
+func (job *Job) rendering() (dpi int, colorMode string, maxPixels float64) {
+	var maxMegapixels int
+	dpi, colorMode, maxMegapixels = job.Rinse.Rendering()
+	if job.Dpi != 0 {
+		dpi = job.Dpi
+	}
+	if job.ColorMode != "" {
+		colorMode = job.ColorMode
+	}
+	maxPixels = float64(maxMegapixels) * 1e6
+	return
+}
+
+func colorModeArgs(colorMode string) []string {
+	switch colorMode {
+	case "gray":
+		return []string{"-gray"}
+	case "mono":
+		return []string{"-mono"}
+	}
+	return nil
+}
+
+var pdfinfoPageSizeRx = regexp.MustCompile(`Page\s+(\d+) size: ([\d.]+) x ([\d.]+) pts`)
+
+// pageSizes returns the size in points of each page of "input.pdf".
+func (job *Job) pageSizes(ctx context.Context) (sizes [][2]float64, err error) {
+	stdouthandler := func(s string, isout bool) (err error) {
+		if isout {
+			for _, m := range pdfinfoPageSizeRx.FindAllStringSubmatch(s, -1) {
+				w, _ := strconv.ParseFloat(m[2], 64)
+				h, _ := strconv.ParseFloat(m[3], 64)
+				sizes = append(sizes, [2]float64{w, h})
+			}
+		}
+		return
+	}
+	err = job.runsc(ctx, stdouthandler, "pdfinfo", "-f", "1", "-l", strconv.Itoa(math.MaxInt32), "/var/rinse/input.pdf")
+	return
+}
+
+// renderRun is a range of pages rendered at the same DPI.
+type renderRun struct {
+	first, last int
+	dpi         int
+}
+
+// renderRuns groups the selected pages into runs of consecutive pages that
+// can be rendered at the same DPI without any page exceeding maxPixels.
+func renderRuns(sizes [][2]float64, pages []int, dpi int, maxPixels float64) (runs []renderRun) {
+	for _, page := range pages {
+		pageDpi := dpi
+		size := sizes[page-1]
+		if area := size[0] * size[1] / (72 * 72); maxPixels > 0 && area > 0 {
+			if capDpi := int(math.Sqrt(maxPixels / area)); capDpi < pageDpi {
+				pageDpi = max(1, capDpi)
+			}
+		}
+		if n := len(runs); n > 0 && runs[n-1].dpi == pageDpi && runs[n-1].last == page-1 {
+			runs[n-1].last = page
+		} else {
+			runs = append(runs, renderRun{first: page, last: page, dpi: pageDpi})
+		}
+	}
+	return
+}
+
+// renderCommand returns the command line that runs pdftoppm with args for each
+// of the runs. More than one run is done by a shell script, so that all pages
+// are rendered in the same sandbox. The args must not need shell quoting.
+func renderCommand(args []string, runs []renderRun) []string {
+	var lines []string
+	for _, run := range runs {
+		cmd := append(slices.Clone(args),
+			"-r", strconv.Itoa(run.dpi), "-f", strconv.Itoa(run.first), "-l", strconv.Itoa(run.last),
+			"/var/rinse/input.pdf", "/var/rinse/output")
+		if len(runs) == 1 {
+			return cmd
+		}
+		lines = append(lines, strings.Join(cmd, " "))
+	}
+	return []string{"sh", "-c", "set -e\n" + strings.Join(lines, "\n")}
+}
+
+// renderArgs returns the command line needed to render
+// the selected pages of "input.pdf".
+func (job *Job) renderArgs(ctx context.Context) (cmd []string, err error) {
+	dpi, colorMode, maxPixels := job.rendering()
+	args := append([]string{"pdftoppm", "-png", "-cropbox"}, colorModeArgs(colorMode)...)
+	var sizes [][2]float64
+	if sizes, err = job.pageSizes(ctx); err == nil && len(sizes) > 0 {
+		job.mu.Lock()
+		job.TotalPages = len(sizes)
+		job.mu.Unlock()
+		var pages []int
+		if pages, err = selectPages(job.PageRanges, len(sizes)); err == nil {
+			cmd = renderCommand(args, renderRuns(sizes, pages, dpi, maxPixels))
+		}
+		return
+	}
+	if job.PageRanges != "" {
+		return nil, fmt.Errorf("%w: page count unknown", ErrNoPagesSelected)
+	}
+	if err != nil {
+		job.Rinse.Warn("pdfinfo", "job", job.Name, "err", err)
+	}
+	return append(args, "-r", strconv.Itoa(dpi), "/var/rinse/input.pdf", "/var/rinse/output"), nil
+}
@@ -0,0 +1,102 @@
+package rinser
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParsePageRanges(t *testing.T) {
+	for _, tc := range []struct {
+		s      string
+		ranges []pageRange
+		normal string
+		err    error
+	}{
+		{"", nil, "", nil},
+		{" , ", nil, "", nil},
+		{"3", []pageRange{{3, 3}}, "3", nil},
+		{"1-5, 10 ,20-", []pageRange{{1, 5}, {10, 10}, {20, 0}}, "1-5,10,20-", nil},
+		{"2 - 2", []pageRange{{2, 2}}, "2", nil},
+		{"0", nil, "", ErrIllegalPageRange},
+		{"-3", nil, "", ErrIllegalPageRange},
+		{"5-3", nil, "", ErrIllegalPageRange},
+		{"1-x", nil, "", ErrIllegalPageRange},
+		{"1,a", nil, "", ErrIllegalPageRange},
+	} {
+		ranges, err := parsePageRanges(tc.s)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%q: err %v, want %v", tc.s, err, tc.err)
+		}
+		if !slices.Equal(ranges, tc.ranges) {
+			t.Errorf("%q: ranges %v, want %v", tc.s, ranges, tc.ranges)
+		}
+		if normal, _ := ParsePageRanges(tc.s); normal != tc.normal {
+			t.Errorf("%q: normal form %q, want %q", tc.s, normal, tc.normal)
+		}
+	}
+}
+
+func TestSelectPages(t *testing.T) {
+	for _, tc := range []struct {
+		s        string
+		numPages int
+		pages    []int
+		err      error
+	}{
+		{"", 3, []int{1, 2, 3}, nil},
+		{"2-", 4, []int{2, 3, 4}, nil},
+		{"3,1-2,2", 5, []int{1, 2, 3}, nil},
+		{"2-10", 3, []int{2, 3}, nil},
+		{"4-", 3, nil, ErrNoPagesSelected},
+		{"", 0, nil, ErrNoPagesSelected},
+		{"0-1", 3, nil, ErrIllegalPageRange},
+	} {
+		pages, err := selectPages(tc.s, tc.numPages)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%q of %d: err %v, want %v", tc.s, tc.numPages, err, tc.err)
+		}
+		if !slices.Equal(pages, tc.pages) {
+			t.Errorf("%q of %d: pages %v, want %v", tc.s, tc.numPages, pages, tc.pages)
+		}
+	}
+}
+
+func TestRenderRuns(t *testing.T) {
+	a4 := [2]float64{595, 842}
+	a0 := [2]float64{2384, 3370}
+	for _, tc := range []struct {
+		sizes     [][2]float64
+		pages     []int
+		maxPixels float64
+		runs      []renderRun
+	}{
+		{[][2]float64{a4, a4, a4}, []int{1, 2, 3}, 0, []renderRun{{1, 3, 300}}},
+		{[][2]float64{a4, a4, a4}, []int{1, 3}, 0, []renderRun{{1, 1, 300}, {3, 3, 300}}},
+		{[][2]float64{a4, a0, a0, a4}, []int{1, 2, 3, 4}, 100e6, []renderRun{{1, 1, 300}, {2, 3, 254}, {4, 4, 300}}},
+		{[][2]float64{a4, {0, 0}}, []int{1, 2}, 1e6, []renderRun{{1, 1, 101}, {2, 2, 300}}},
+		{[][2]float64{{1e6, 1e6}}, []int{1}, 1, []renderRun{{1, 1, 1}}},
+	} {
+		if runs := renderRuns(tc.sizes, tc.pages, 300, tc.maxPixels); !slices.Equal(runs, tc.runs) {
+			t.Errorf("%v pages %v max %v: runs %v, want %v", tc.sizes, tc.pages, tc.maxPixels, runs, tc.runs)
+		}
+	}
+}
+
+func TestRenderCommand(t *testing.T) {
+	args := []string{"pdftoppm", "-png"}
+	one := renderCommand(args, []renderRun{{2, 4, 150}})
+	if want := []string{"pdftoppm", "-png", "-r", "150", "-f", "2", "-l", "4", "/var/rinse/input.pdf", "/var/rinse/output"}; !slices.Equal(one, want) {
+		t.Errorf("one run: %q", one)
+	}
+	two := renderCommand(args, []renderRun{{1, 1, 300}, {2, 3, 200}})
+	want := []string{"sh", "-c", "set -e\n" +
+		"pdftoppm -png -r 300 -f 1 -l 1 /var/rinse/input.pdf /var/rinse/output\n" +
+		"pdftoppm -png -r 200 -f 2 -l 3 /var/rinse/input.pdf /var/rinse/output"}
+	if !slices.Equal(two, want) {
+		t.Errorf("two runs: %q", two)
+	}
+	if !slices.Equal(args, []string{"pdftoppm", "-png"}) {
+		t.Errorf("args changed: %q", args)
+	}
+}
@@ -0,0 +1,164 @@
+package rinser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+var ErrJobNotFailed = errors.New("job has not failed")
+
+type downloadStatusError struct {
+	code   int
+	status string
+}
+
+func (e downloadStatusError) Error() string {
+	return e.status
+}
+
+// isTransientFailure returns true if err is likely to go away if
+// the job is retried, such as network or sandbox start errors.
+func isTransientFailure(err error) bool {
+	var statuserr downloadStatusError
+	var neterr net.Error
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.Is(err, ErrJobInterrupted), errors.Is(err, ErrSandboxStart):
+		return true
+	case errors.As(err, &statuserr):
+		return statuserr.code >= 500 || statuserr.code == http.StatusTooManyRequests
+	case errors.As(err, &neterr):
+		return true
+	}
+	return false
+}
+
+// scheduleRetry arranges for a failed job to be retried automatically
+// if err is transient and the retry policy allows it.
+func (job *Job) scheduleRetry(err error) {
+	if isTransientFailure(err) {
+		retryMax, delay := job.Rinse.RetryPolicy()
+		job.mu.Lock()
+		if job.Retries < retryMax && !job.closed {
+			job.retryAt = time.Now().Add(delay << job.Retries)
+		}
+		job.mu.Unlock()
+	}
+}
+
+// StoppedCh returns a channel that is closed when the current run of the job stops.
+// Retrying the job starts a new run with a new channel.
+func (job *Job) StoppedCh() (ch <-chan struct{}) {
+	job.mu.Lock()
+	ch = job.stoppedCh
+	job.mu.Unlock()
+	return
+}
+
+func (job *Job) RetryAt() (t time.Time) {
+	job.mu.Lock()
+	t = job.retryAt
+	job.mu.Unlock()
+	return
+}
+
+func (job *Job) Retryable() (yes bool) {
+	job.mu.Lock()
+	yes = job.state == JobFailed && !job.closed
+	job.mu.Unlock()
+	return
+}
+
+// resetDownload removes any partially downloaded files.
+func (job *Job) resetDownload() (err error) {
+	if err = scrub(job.Datadir); err == nil {
+		if err = os.Mkdir(job.Datadir, 0777); err == nil /* #nosec G301 */ {
+			job.mu.Lock()
+			job.docName = ""
+			job.PdfName = ""
+			job.mu.Unlock()
+		}
+	}
+	return
+}
+
+// Retry restarts a failed job from the stage that failed,
+// reusing the files already in the job working directory.
+// It does not count against the automatic retries.
+func (job *Job) Retry() error {
+	return job.retry(false)
+}
+
+func (job *Job) retry(automatic bool) (err error) {
+	err = ErrJobNotFailed
+	if job.Retryable() {
+		job.mu.Lock()
+		resume := job.errstate
+		job.mu.Unlock()
+		err = nil
+		if resume == JobDownload && job.isURL() {
+			err = job.resetDownload()
+		}
+		if err == nil {
+			rns := job.Rinse
+			rns.mu.Lock()
+			if !automatic {
+				// a manual retry queues the job again, like adding it would
+				err = rns.checkRetryQuotaLocked(job)
+			}
+			if err == nil {
+				job.mu.Lock()
+				err = ErrJobNotFailed
+				if job.state == JobFailed && !job.closed {
+					err = nil
+					job.resume = resume
+					job.state = JobNew
+					job.Error = nil
+					job.Done = false
+					job.stopped = time.Time{}
+					job.retryAt = time.Time{}
+					job.stoppedCh = make(chan struct{})
+					if automatic {
+						job.Retries++
+					} else {
+						job.ManualRetries++
+					}
+					for fn := range job.imgfiles {
+						job.imgfiles[fn] = false
+					}
+				}
+				job.mu.Unlock()
+			}
+			rns.mu.Unlock()
+			if err == nil {
+				job.save()
+				rns.Info("job retry", "job", job.Name, "email", job.Email, "stage", jobStateText(resume), "automatic", automatic)
+				rns.Jaws.Dirty(job, uiJobStatus{job})
+				err = rns.MaybeStartJob()
+			}
+		}
+	}
+	return
+}
+
+// resumeStages returns the stages remaining when resuming the job.
+func (job *Job) resumeStages(stages []*registeredStage) ([]*registeredStage, error) {
+	job.mu.Lock()
+	resume := job.resume
+	job.mu.Unlock()
+	if resume != JobNew && resume != JobStarting {
+		for i, stage := range stages {
+			if stage.state == resume {
+				return stages[i:], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: can't resume at %q", ErrUnknownStage, jobStateText(resume))
+	}
+	return stages, nil
+}
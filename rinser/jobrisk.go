@@ -0,0 +1,253 @@
+package rinser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kinds of risky content found in documents.
+const (
+	RiskMacros         = "macros"
+	RiskJavaScript     = "javascript"
+	RiskEmbedded       = "embedded"
+	RiskExternalLinks  = "externallinks"
+	RiskEncrypted      = "encrypted"
+	RiskHiddenSheets   = "hiddensheets"
+	RiskTrackedChanges = "trackedchanges"
+	RiskComments       = "comments"
+)
+
+// riskWeights is how much each kind of finding adds to the risk score.
+var riskWeights = map[string]int{
+	RiskMacros:         40,
+	RiskJavaScript:     40,
+	RiskEmbedded:       20,
+	RiskEncrypted:      20,
+	RiskExternalLinks:  10,
+	RiskHiddenSheets:   10,
+	RiskTrackedChanges: 5,
+	RiskComments:       5,
+}
+
+// riskKinds lists the kinds of findings in the order they are reported.
+var riskKinds = []string{RiskMacros, RiskJavaScript, RiskEmbedded, RiskEncrypted, RiskExternalLinks, RiskHiddenSheets, RiskTrackedChanges, RiskComments}
+
+// maxRiskDetails is the most details kept for each finding.
+const maxRiskDetails = 10
+
+// RiskFinding is one kind of risky content found in a document.
+type RiskFinding struct {
+	Kind    string   `json:"kind" example:"macros"`
+	Count   int      `json:"count" example:"1"`
+	Details []string `json:"details,omitempty" example:"word/vbaProject.bin"`
+}
+
+// Risk summarizes the risky content that rinsing removed from a document.
+type Risk struct {
+	Score    int           `json:"score" example:"40"` // 0 to 100
+	Level    string        `json:"level" example:"medium"`
+	Findings []RiskFinding `json:"findings,omitempty"`
+}
+
+var riskURLRx = regexp.MustCompile(`(?i)\b(?:https?|ftp|file)://[^\s"'<>()\[\]]+`)
+
+func metaTruthy(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v > 0
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n > 0
+		}
+		return strings.EqualFold(v, "yes")
+	case []any:
+		return slices.ContainsFunc(v, metaTruthy)
+	}
+	return false
+}
+
+type riskScan struct {
+	found map[string]*RiskFinding
+}
+
+func (rs *riskScan) add(kind, detail string) {
+	f := rs.found[kind]
+	if f == nil {
+		f = &RiskFinding{Kind: kind}
+		rs.found[kind] = f
+	}
+	f.Count++
+	if detail != "" && len(f.Details) < maxRiskDetails && !slices.Contains(f.Details, detail) {
+		f.Details = append(f.Details, detail)
+	}
+}
+
+// flagKeys maps substrings of lower case Tika metadata keys with a true or
+// positive value to the kind of finding they indicate.
+var flagKeys = []struct{ substr, kind string }{
+	{"macro", RiskMacros},
+	{"vbaproject", RiskMacros},
+	{"javascript", RiskJavaScript},
+	{"encrypted", RiskEncrypted},
+	{"hiddensheet", RiskHiddenSheets},
+	{"veryhiddensheet", RiskHiddenSheets},
+	{"trackchange", RiskTrackedChanges},
+	{"trackedchange", RiskTrackedChanges},
+	{"hascomments", RiskComments},
+	{"commentcount", RiskComments},
+	{"comment-count", RiskComments},
+}
+
+// scanPart looks for risky content in the metadata of one document part.
+func (rs *riskScan) scanPart(part map[string]any) {
+	for key, v := range part {
+		lkey := strings.ToLower(key)
+		if metaTruthy(v) {
+			for _, fk := range flagKeys {
+				if strings.Contains(lkey, fk.substr) {
+					rs.add(fk.kind, key)
+					break
+				}
+			}
+		}
+	}
+	ct := strings.ToLower(metaString(part["Content-Type"]))
+	if strings.Contains(ct, "macroenabled") {
+		rs.add(RiskMacros, ct)
+	}
+	switch strings.ToUpper(metaString(part["X-TIKA:embedded_resource_type"])) {
+	case "MACRO":
+		if strings.Contains(ct, "javascript") {
+			rs.add(RiskJavaScript, metaString(part["X-TIKA:embedded_resource_path"]))
+		} else {
+			rs.add(RiskMacros, metaString(part["X-TIKA:embedded_resource_path"]))
+		}
+	default:
+		if p := metaString(part["X-TIKA:embedded_resource_path"]); p != "" {
+			rs.add(RiskEmbedded, p)
+		}
+	}
+	for _, key := range []string{"pdf:actionTypes", "pdf:actionTrigger", "pdf:actionTriggers"} {
+		if s := metaString(part[key]); strings.Contains(strings.ToLower(s), "javascript") {
+			rs.add(RiskJavaScript, key)
+		}
+	}
+	if s := metaString(part["pdf:annotationSubtypes"]); s != "" {
+		for _, sub := range strings.Split(s, ", ") {
+			switch sub {
+			case "Text", "FreeText":
+				rs.add(RiskComments, "pdf:"+sub)
+			case "Link":
+				rs.add(RiskExternalLinks, "pdf:Link")
+			}
+		}
+	}
+	for _, s := range riskURLRx.FindAllString(metaString(part["X-TIKA:content"]), -1) {
+		// only the host, the rest of the URL is document content
+		var host string
+		if u, err := url.Parse(s); err == nil {
+			host = strings.ToLower(u.Hostname())
+		}
+		rs.add(RiskExternalLinks, host)
+	}
+}
+
+func (rs *riskScan) risk() (risk Risk) {
+	for _, kind := range riskKinds {
+		if f := rs.found[kind]; f != nil {
+			risk.Findings = append(risk.Findings, *f)
+			risk.Score += riskWeights[kind]
+		}
+	}
+	risk.Score = min(100, risk.Score)
+	switch {
+	case risk.Score == 0:
+		risk.Level = "none"
+	case risk.Score < 20:
+		risk.Level = "low"
+	case risk.Score < 50:
+		risk.Level = "medium"
+	default:
+		risk.Level = "high"
+	}
+	return
+}
+
+// AssessRisk returns the risk summary of the Tika JSON metadata in b,
+// which is either a single metadata object or a list of them, one for
+// the document and one for each embedded document.
+func AssessRisk(b []byte) (risk Risk, err error) {
+	var parts []map[string]any
+	if err = json.Unmarshal(b, &parts); err != nil {
+		var part map[string]any
+		if err = json.Unmarshal(b, &part); err == nil {
+			parts = []map[string]any{part}
+		}
+	}
+	if err == nil {
+		risk = assessParts(parts)
+	}
+	return
+}
+
+// assessParts returns the risk summary of the Tika metadata of a document
+// and its embedded documents.
+func assessParts(parts []map[string]any) Risk {
+	rs := riskScan{found: map[string]*RiskFinding{}}
+	for _, part := range parts {
+		rs.scanPart(part)
+	}
+	return rs.risk()
+}
+
+// assessRisk records the risk summary of the document metadata file.
+func (job *Job) assessRisk() (err error) {
+	var b []byte
+	if b, err = os.ReadFile(job.MetaPath()); err == nil /* #nosec G304 */ {
+		err = job.setRisk(b)
+	}
+	return
+}
+
+// setRisk records the risk summary of the Tika JSON metadata in b.
+func (job *Job) setRisk(b []byte) (err error) {
+	var risk Risk
+	if risk, err = AssessRisk(b); err == nil {
+		job.putRisk(&risk)
+	}
+	return
+}
+
+func (job *Job) putRisk(risk *Risk) {
+	job.mu.Lock()
+	job.Risk = risk
+	job.mu.Unlock()
+	job.Rinse.Jaws.Dirty(uiJobRisk{job})
+}
+
+// GetRisk returns the risk summary of the document, or nil if not yet known.
+func (job *Job) GetRisk() (risk *Risk) {
+	job.mu.Lock()
+	risk = job.Risk
+	job.mu.Unlock()
+	return
+}
+
+func (risk *Risk) String() string {
+	var kinds []string
+	for _, f := range risk.Findings {
+		kinds = append(kinds, fmt.Sprintf("%s (%d)", f.Kind, f.Count))
+	}
+	return strings.Join(kinds, ", ")
+}
@@ -0,0 +1,190 @@
+package rinser
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrMalwareFound = errors.New("malware found")
+var ErrQuarantined = errors.New("job is quarantined")
+var ErrClamd = errors.New("clamd error")
+var ErrIllegalScanPolicy = errors.New("illegal scan policy")
+
+// Scan policies decide what happens to a job whose document is found to be malware.
+const (
+	ScanReject   = "reject"   // fail the job
+	ScanFlag     = "flag"     // rinse the document, but only let admins download the results
+	ScanContinue = "continue" // rinse the document as usual
+)
+
+var ScanPolicies = []string{ScanReject, ScanFlag, ScanContinue}
+
+// Scan verdicts recorded on jobs.
+const (
+	VerdictClean    = "clean"
+	VerdictInfected = "infected"
+)
+
+// clamdChunkSize is the most data sent in one INSTREAM chunk.
+const clamdChunkSize = 64 * 1024
+
+// ClamdSettings configures scanning documents for malware using clamd.
+type ClamdSettings struct {
+	Address    string // "host:port", "tcp://host:port" or "unix:/path/to/clamd.sock", empty to not scan
+	Policy     string // one of ScanPolicies
+	TimeoutSec int    // most time a scan may take
+}
+
+func CheckScanPolicy(policy string) error {
+	if slices.Contains(ScanPolicies, policy) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrIllegalScanPolicy, policy)
+}
+
+func (rns *Rinse) ClamdSettings() (cs ClamdSettings) {
+	rns.mu.Lock()
+	cs = rns.clamd
+	rns.mu.Unlock()
+	return
+}
+
+// clamdAddr returns the network and address to dial for a clamd address.
+func clamdAddr(address string) (network, addr string) {
+	if s, ok := strings.CutPrefix(address, "unix://"); ok {
+		return "unix", s
+	}
+	if s, ok := strings.CutPrefix(address, "unix:"); ok {
+		return "unix", s
+	}
+	if strings.HasPrefix(address, "/") {
+		return "unix", address
+	}
+	return "tcp", strings.TrimPrefix(address, "tcp://")
+}
+
+// ClamdScan streams r to the clamd at address using the INSTREAM command,
+// and returns the name of the malware found, if any.
+func ClamdScan(ctx context.Context, address string, r io.Reader) (malware string, err error) {
+	network, addr := clamdAddr(address)
+	var d net.Dialer
+	var conn net.Conn
+	if conn, err = d.DialContext(ctx, network, addr); err == nil {
+		defer conn.Close()
+		// unblock I/O once ctx is done, so that ctx.Err() is set when it fails
+		stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+		defer stop()
+		if _, err = io.WriteString(conn, "zINSTREAM\x00"); err == nil {
+			buf := make([]byte, 4+clamdChunkSize)
+			for err == nil {
+				var n int
+				if n, err = io.ReadFull(r, buf[4:]); err == io.ErrUnexpectedEOF || err == io.EOF {
+					err = io.EOF
+				}
+				if err == nil || (err == io.EOF && n > 0) {
+					binary.BigEndian.PutUint32(buf, uint32(n)) // #nosec G115
+					if _, e := conn.Write(buf[:4+n]); e != nil {
+						err = e
+					}
+				}
+			}
+			if err == io.EOF {
+				if _, err = conn.Write([]byte{0, 0, 0, 0}); err == nil {
+					var reply []byte
+					if reply, err = io.ReadAll(io.LimitReader(conn, 4096)); err == nil {
+						malware, err = parseClamdReply(string(bytes.TrimRight(reply, "\x00\n")))
+					}
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}
+	return
+}
+
+// parseClamdReply parses the reply to an INSTREAM command, such as
+// "stream: OK" or "stream: Eicar-Test-Signature FOUND".
+func parseClamdReply(reply string) (malware string, err error) {
+	s := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case s == "OK":
+	case strings.HasSuffix(s, " FOUND"):
+		malware = strings.TrimSuffix(s, " FOUND")
+	default:
+		err = fmt.Errorf("%w: %s", ErrClamd, reply)
+	}
+	return
+}
+
+// runScan scans the document for malware if clamd is configured,
+// and applies the scan policy if it is found.
+func (job *Job) runScan(ctx context.Context) (err error) {
+	var fn string
+	if fn, err = job.DocumentFile(); err == nil {
+		cs := job.Rinse.ClamdSettings()
+		if cs.Address != "" {
+			if cs.TimeoutSec > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, time.Duration(cs.TimeoutSec)*time.Second)
+				defer cancel()
+			}
+			var f *os.File
+			if f, err = os.Open(path.Join(job.Datadir, fn)); err == nil /* #nosec G304 */ {
+				defer f.Close()
+				var malware string
+				if malware, err = ClamdScan(ctx, cs.Address, f); err == nil {
+					job.madeProgress()
+					job.mu.Lock()
+					job.Verdict = VerdictClean
+					if malware != "" {
+						job.Verdict = VerdictInfected
+						job.Malware = malware
+						job.Quarantined = cs.Policy == ScanFlag
+					}
+					job.mu.Unlock()
+					if malware != "" {
+						job.Rinse.Warn("malware found", "job", job.Name, "email", job.Email, "malware", malware, "policy", cs.Policy)
+						metricMalware.WithLabelValues(cs.Policy).Inc()
+						if cs.Policy == ScanReject {
+							err = fmt.Errorf("%w: %s", ErrMalwareFound, malware)
+						}
+					}
+					job.save()
+				}
+			}
+		}
+	}
+	return
+}
+
+// IsQuarantined returns true if malware was found in the document
+// and the results may only be downloaded by admins.
+func (job *Job) IsQuarantined() (yes bool) {
+	job.mu.Lock()
+	yes = job.Quarantined
+	job.mu.Unlock()
+	return
+}
+
+// quarantined returns true if the job is quarantined and the request is not from an admin.
+func (rns *Rinse) quarantined(hr *http.Request, job *Job) bool {
+	return job.IsQuarantined() && !rns.IsAdmin(rns.GetEmail(hr))
+}
+
+// downloadable returns the jobs whose results the request may download.
+func (rns *Rinse) downloadable(hr *http.Request, jobs []*Job) []*Job {
+	return slices.DeleteFunc(slices.Clone(jobs), func(job *Job) bool { return rns.quarantined(hr, job) })
+}
@@ -0,0 +1,251 @@
+package rinser
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linkdata/webserv"
+)
+
+const eicar = "Eicar-Test-Signature"
+
+// fakeClamd answers INSTREAM commands the way clamd does, recording
+// the chunk sizes and data it was sent.
+type fakeClamd struct {
+	t       *testing.T
+	address string
+	reply   string // sent after the terminating chunk, nothing if empty
+	mu      sync.Mutex
+	chunks  []int
+	data    []byte
+	done    bool // the zero-length terminator was received
+}
+
+func newFakeClamd(t *testing.T, network, reply string) (fc *fakeClamd) {
+	t.Helper()
+	addr := "127.0.0.1:0"
+	if network == "unix" {
+		dir, err := os.MkdirTemp("", "clamd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = os.RemoveAll(dir) })
+		addr = path.Join(dir, "clamd.sock")
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc = &fakeClamd{t: t, reply: reply}
+	if network == "unix" {
+		fc.address = "unix:" + l.Addr().String()
+	} else {
+		fc.address = "tcp://" + l.Addr().String()
+	}
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		_ = l.Close()
+		wg.Wait()
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				fc.serve(conn)
+			}()
+		}
+	}()
+	return
+}
+
+func (fc *fakeClamd) serve(conn net.Conn) {
+	cmd := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, cmd); err != nil || string(cmd) != "zINSTREAM\x00" {
+		fc.t.Errorf("command %q: %v", cmd, err)
+		return
+	}
+	for {
+		var size uint32
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return
+		}
+		fc.mu.Lock()
+		fc.chunks = append(fc.chunks, int(size))
+		fc.data = append(fc.data, b...)
+		fc.mu.Unlock()
+	}
+	fc.mu.Lock()
+	fc.done = true
+	fc.mu.Unlock()
+	if fc.reply == "" {
+		// hang until the client gives up
+		_, _ = io.Copy(io.Discard, conn)
+		return
+	}
+	_, _ = io.WriteString(conn, fc.reply+"\x00")
+}
+
+func (fc *fakeClamd) received() (chunks []int, data []byte, done bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return slices.Clone(fc.chunks), bytes.Clone(fc.data), fc.done
+}
+
+func TestClamdScanReplies(t *testing.T) {
+	for _, network := range []string{"unix", "tcp"} {
+		for _, tc := range []struct {
+			reply   string
+			malware string
+			err     error
+		}{
+			{"stream: OK", "", nil},
+			{"stream: " + eicar + " FOUND", eicar, nil},
+			{"INSTREAM size limit exceeded. ERROR", "", ErrClamd},
+		} {
+			t.Run(network+" "+tc.reply, func(t *testing.T) {
+				fc := newFakeClamd(t, network, tc.reply)
+				malware, err := ClamdScan(context.Background(), fc.address, bytes.NewReader([]byte("document")))
+				if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+					t.Fatalf("err %v, want %v", err, tc.err)
+				}
+				if malware != tc.malware {
+					t.Errorf("malware %q, want %q", malware, tc.malware)
+				}
+			})
+		}
+	}
+}
+
+func TestClamdScanChunks(t *testing.T) {
+	for _, tc := range []struct {
+		size   int
+		chunks []int
+	}{
+		{0, nil},
+		{10, []int{10}},
+		{clamdChunkSize, []int{clamdChunkSize}},
+		{2*clamdChunkSize + 10, []int{clamdChunkSize, clamdChunkSize, 10}},
+	} {
+		fc := newFakeClamd(t, "tcp", "stream: OK")
+		doc := bytes.Repeat([]byte("0123456789"), tc.size/10+1)[:tc.size]
+		if _, err := ClamdScan(context.Background(), fc.address, bytes.NewReader(doc)); err != nil {
+			t.Fatal(err)
+		}
+		chunks, data, done := fc.received()
+		if !slices.Equal(chunks, tc.chunks) {
+			t.Errorf("size %d: chunks %v, want %v", tc.size, chunks, tc.chunks)
+		}
+		if !bytes.Equal(data, doc) {
+			t.Errorf("size %d: data differs", tc.size)
+		}
+		if !done {
+			t.Errorf("size %d: no zero-length terminator", tc.size)
+		}
+	}
+}
+
+func TestClamdScanTimeout(t *testing.T) {
+	fc := newFakeClamd(t, "unix", "")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := ClamdScan(ctx, fc.address, bytes.NewReader([]byte("document")))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %v", elapsed)
+	}
+}
+
+func newScanJob(t *testing.T, cs ClamdSettings) *Job {
+	t.Helper()
+	dataDir := t.TempDir()
+	if err := os.Mkdir(path.Join(dataDir, "jobs"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	jobDir := t.TempDir()
+	if err := os.WriteFile(path.Join(jobDir, "doc.pdf"), []byte("document"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rns := &Rinse{Config: &webserv.Config{DataDir: dataDir}, clamd: cs}
+	return &Job{Rinse: rns, Name: "doc.pdf", UUID: uuid.New(), Workdir: jobDir, Datadir: jobDir, docName: "doc.pdf"}
+}
+
+func TestRunScanPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy      string
+		err         error
+		quarantined bool
+	}{
+		{ScanReject, ErrMalwareFound, false},
+		{ScanFlag, nil, true},
+		{ScanContinue, nil, false},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			fc := newFakeClamd(t, "tcp", "stream: "+eicar+" FOUND")
+			job := newScanJob(t, ClamdSettings{Address: fc.address, Policy: tc.policy, TimeoutSec: 5})
+			err := job.runScan(context.Background())
+			if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+				t.Fatalf("err %v, want %v", err, tc.err)
+			}
+			if job.Verdict != VerdictInfected || job.Malware != eicar {
+				t.Errorf("verdict %q malware %q", job.Verdict, job.Malware)
+			}
+			if job.IsQuarantined() != tc.quarantined {
+				t.Errorf("quarantined %v, want %v", job.IsQuarantined(), tc.quarantined)
+			}
+			if _, err := os.Stat(job.recordPath()); err != nil {
+				t.Errorf("not saved: %v", err)
+			}
+		})
+	}
+
+	t.Run("clean", func(t *testing.T) {
+		fc := newFakeClamd(t, "unix", "stream: OK")
+		job := newScanJob(t, ClamdSettings{Address: fc.address, Policy: ScanReject})
+		if err := job.runScan(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if job.Verdict != VerdictClean || job.Malware != "" || job.IsQuarantined() {
+			t.Errorf("verdict %q malware %q quarantined %v", job.Verdict, job.Malware, job.IsQuarantined())
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		fc := newFakeClamd(t, "tcp", "INSTREAM size limit exceeded. ERROR")
+		job := newScanJob(t, ClamdSettings{Address: fc.address, Policy: ScanContinue})
+		if err := job.runScan(context.Background()); !errors.Is(err, ErrClamd) {
+			t.Errorf("err %v", err)
+		}
+		if job.Verdict != "" {
+			t.Errorf("verdict %q", job.Verdict)
+		}
+	})
+}
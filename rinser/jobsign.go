@@ -0,0 +1,410 @@
+package rinser
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/digitorus/pkcs7"
+	"github.com/google/uuid"
+)
+
+var ErrSignUnsupported = errors.New("PDF structure not supported for signing")
+var ErrNotSigned = errors.New("PDF has no signature")
+var ErrSignatureMismatch = errors.New("PDF signature does not cover the whole document")
+var ErrForeignSigner = errors.New("PDF not signed by this instance")
+var ErrVerifyTooLarge = errors.New("PDF too large to check the signature of")
+
+// MaxVerifySignatureSize is the largest PDF whose signature VerifyPDF checks.
+// Larger PDFs are only hashed, so they can still be found by their job.
+const MaxVerifySignatureSize = 64 * 1024 * 1024
+
+// Files in DataDir holding the PEM encoded certificate chain and private key
+// used to sign rinsed PDFs. Signing is enabled if both exist.
+const (
+	SigningCertName = "signing.crt"
+	SigningKeyName  = "signing.key"
+)
+
+var oidAttributeSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+
+// essCertIDv2 identifies the signing certificate by its SHA-256 hash (RFC 5035).
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// pdfSigner signs rinsed PDFs with a certificate and key held by the server.
+type pdfSigner struct {
+	cert    *x509.Certificate
+	parents []*x509.Certificate
+	key     crypto.PrivateKey
+}
+
+// loadSigner loads the signing certificate and key from dir,
+// returning nil if either file doesn't exist.
+func loadSigner(dir string) (s *pdfSigner, err error) {
+	certFile := path.Join(dir, SigningCertName)
+	keyFile := path.Join(dir, SigningKeyName)
+	if fileExists(certFile) && fileExists(keyFile) {
+		var pair tls.Certificate
+		if pair, err = tls.LoadX509KeyPair(certFile, keyFile); err == nil {
+			var certs []*x509.Certificate
+			for _, der := range pair.Certificate {
+				var cert *x509.Certificate
+				if cert, err = x509.ParseCertificate(der); err != nil {
+					return
+				}
+				certs = append(certs, cert)
+			}
+			s = &pdfSigner{cert: certs[0], parents: certs[1:], key: pair.PrivateKey}
+		}
+	}
+	return
+}
+
+// fingerprint returns the SHA-256 of the signing certificate.
+func (s *pdfSigner) fingerprint() string {
+	h := sha256.Sum256(s.cert.Raw)
+	return hex.EncodeToString(h[:])
+}
+
+// detached returns a detached CMS signature of data.
+func (s *pdfSigner) detached(data []byte) (sig []byte, err error) {
+	var sd *pkcs7.SignedData
+	if sd, err = pkcs7.NewSignedData(data); err == nil {
+		sd.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+		h := sha256.Sum256(s.cert.Raw)
+		config := pkcs7.SignerInfoConfig{
+			ExtraSignedAttributes: []pkcs7.Attribute{{
+				Type:  oidAttributeSigningCertificateV2,
+				Value: signingCertificateV2{Certs: []essCertIDv2{{CertHash: h[:]}}},
+			}},
+		}
+		if err = sd.AddSignerChain(s.cert, s.key, s.parents, config); err == nil {
+			sd.Detach()
+			sig, err = sd.Finish()
+		}
+	}
+	return
+}
+
+// maxSignatureSize returns the space to reserve for a signature.
+func (s *pdfSigner) maxSignatureSize() (n int) {
+	n = 8192 + len(s.cert.Raw)
+	for _, cert := range s.parents {
+		n += len(cert.Raw)
+	}
+	return
+}
+
+var (
+	pdfRootRx      = regexp.MustCompile(`/Root\s+(\d+)\s+(\d+)\s+R`)
+	pdfSizeRx      = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfInfoRx      = regexp.MustCompile(`/Info\s+\d+\s+\d+\s+R`)
+	pdfIDRx        = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+	pdfByteRangeRx = regexp.MustCompile(`/ByteRange\s*\[\s*(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s*\]`)
+)
+
+// pdfTrailer is what signing needs from the trailer of the last revision of a PDF.
+type pdfTrailer struct {
+	xref     int    // offset of the cross-reference section
+	isStream bool   // cross-reference stream rather than table
+	root     int    // catalog object number
+	rootGen  int    // catalog generation number
+	size     int    // highest object number plus one
+	extra    string // Info and ID entries to carry over
+}
+
+func readTrailer(b []byte) (t pdfTrailer, err error) {
+	err = ErrSignUnsupported
+	if i := bytes.LastIndex(b, []byte("startxref")); i >= 0 {
+		if fields := strings.Fields(string(b[i+len("startxref"):])); len(fields) > 0 {
+			if xref, e := strconv.Atoi(fields[0]); e == nil && xref >= 0 && xref < len(b) {
+				t.xref = xref
+				var dict []byte
+				rest := b[t.xref:]
+				if bytes.HasPrefix(rest, []byte("xref")) {
+					if j := bytes.Index(rest, []byte("trailer")); j >= 0 {
+						dict = rest[j:]
+						if k := bytes.Index(dict, []byte("startxref")); k >= 0 {
+							dict = dict[:k]
+						}
+					}
+				} else if j := bytes.Index(rest, []byte("stream")); j >= 0 {
+					t.isStream = true
+					dict = rest[:j]
+				}
+				root := pdfRootRx.FindSubmatch(dict)
+				size := pdfSizeRx.FindSubmatch(dict)
+				if root != nil && size != nil && !bytes.Contains(dict, []byte("/Encrypt")) {
+					t.root, _ = strconv.Atoi(string(root[1]))
+					t.rootGen, _ = strconv.Atoi(string(root[2]))
+					t.size, _ = strconv.Atoi(string(size[1]))
+					for _, rx := range []*regexp.Regexp{pdfInfoRx, pdfIDRx} {
+						if m := rx.Find(dict); m != nil {
+							t.extra += " " + string(m)
+						}
+					}
+					err = nil
+				}
+			}
+		}
+	}
+	return
+}
+
+// readCatalog returns the entries of the last definition of the catalog object.
+func readCatalog(b []byte, t pdfTrailer) (entries string, err error) {
+	err = ErrSignUnsupported
+	rx := regexp.MustCompile(fmt.Sprintf(`(?s)(?:^|\s)%d\s+%d\s+obj\s*<<(.*?)>>\s*endobj`, t.root, t.rootGen))
+	if m := rx.FindAllSubmatch(b, -1); m != nil {
+		entries = string(m[len(m)-1][1])
+		if !strings.Contains(entries, "/AcroForm") {
+			err = nil
+		}
+	}
+	return
+}
+
+func pdfDate(t time.Time) string {
+	return t.UTC().Format("D:20060102150405Z")
+}
+
+// pdfString returns s as a PDF literal string.
+func pdfString(s string) string {
+	return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s) + ")"
+}
+
+// signPDF returns the PDF in b with an invisible PAdES signature added
+// as an incremental update.
+func (s *pdfSigner) signPDF(b []byte, reason string) (signed []byte, err error) {
+	var t pdfTrailer
+	if t, err = readTrailer(b); err == nil {
+		var catalog string
+		if catalog, err = readCatalog(b, t); err == nil {
+			var buf bytes.Buffer
+			buf.Write(b)
+			if !bytes.HasSuffix(b, []byte("\n")) {
+				buf.WriteByte('\n')
+			}
+			sigNum, fieldNum := t.size, t.size+1
+			offsets := map[int]int{}
+
+			offsets[sigNum] = buf.Len()
+			fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /Sig /Filter /Adobe.PPKLite /SubFilter /ETSI.CAdES.detached /ByteRange [0 ", sigNum)
+			byteRangePos := buf.Len()
+			buf.WriteString(strings.Repeat(" ", 32) + "] /Contents ")
+			contentsPos := buf.Len()
+			buf.WriteString("<" + strings.Repeat("0", 2*s.maxSignatureSize()) + ">")
+			contentsEnd := buf.Len()
+			fmt.Fprintf(&buf, " /M %s /Name %s /Reason %s >>\nendobj\n",
+				pdfString(pdfDate(time.Now())), pdfString(s.cert.Subject.CommonName), pdfString(reason))
+
+			offsets[fieldNum] = buf.Len()
+			fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /Annot /Subtype /Widget /FT /Sig /T (Signature1) /V %d 0 R /F 132 /Rect [0 0 0 0] >>\nendobj\n",
+				fieldNum, sigNum)
+
+			offsets[t.root] = buf.Len()
+			fmt.Fprintf(&buf, "%d %d obj\n<<%s /AcroForm << /Fields [%d 0 R] /SigFlags 3 >> >>\nendobj\n",
+				t.root, t.rootGen, catalog, fieldNum)
+
+			if t.isStream {
+				xrefNum := t.size + 2
+				offsets[xrefNum] = buf.Len()
+				var rows bytes.Buffer
+				for _, num := range []int{t.root, sigNum, fieldNum, xrefNum} {
+					gen := 0
+					if num == t.root {
+						gen = t.rootGen
+					}
+					rows.WriteByte(1)
+					_ = binary.Write(&rows, binary.BigEndian, uint32(offsets[num])) // #nosec G115
+					_ = binary.Write(&rows, binary.BigEndian, uint16(gen))          // #nosec G115
+				}
+				fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Index [%d 1 %d 3] /Root %d %d R /Prev %d%s /Length %d >>\nstream\n",
+					xrefNum, xrefNum+1, t.root, sigNum, t.root, t.rootGen, t.xref, t.extra, rows.Len())
+				buf.Write(rows.Bytes())
+				fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", offsets[xrefNum])
+			} else {
+				xref := buf.Len()
+				buf.WriteString("xref\n")
+				fmt.Fprintf(&buf, "%d 1\n%010d %05d n\r\n", t.root, offsets[t.root], t.rootGen)
+				fmt.Fprintf(&buf, "%d 2\n%010d 00000 n\r\n%010d 00000 n\r\n", sigNum, offsets[sigNum], offsets[fieldNum])
+				fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d %d R /Prev %d%s >>\nstartxref\n%d\n%%%%EOF\n",
+					t.size+2, t.root, t.rootGen, t.xref, t.extra, xref)
+			}
+
+			signed = buf.Bytes()
+			byteRange := fmt.Sprintf("%d %d %d", contentsPos, contentsEnd, len(signed)-contentsEnd)
+			copy(signed[byteRangePos:], byteRange)
+			content := append(signed[:contentsPos:contentsPos], signed[contentsEnd:]...)
+			var sig []byte
+			if sig, err = s.detached(content); err == nil {
+				if len(sig) > s.maxSignatureSize() {
+					err = fmt.Errorf("signature too large: %d bytes", len(sig))
+				} else {
+					hex.Encode(signed[contentsPos+1:], sig)
+				}
+			}
+		}
+	}
+	return
+}
+
+// Verification is the result of checking whether a PDF was produced by this instance.
+type Verification struct {
+	Produced    bool      `json:"produced" example:"true"`                      // the PDF was produced by this instance
+	Signed      bool      `json:"signed" example:"true"`                        // has a valid signature by this instance covering the whole document
+	Signer      string    `json:"signer,omitempty" example:"rinse.example.com"` // subject of the signing certificate
+	SigningTime time.Time `json:"signingtime,omitzero" example:"2024-01-01T12:00:00+00:00" format:"dateTime"`
+	SHA256      string    `json:"sha256" example:"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"`
+	Job         uuid.UUID `json:"job,omitzero" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // that produced the PDF, if still known
+	Error       string    `json:"error,omitempty" example:"PDF has no signature"`                            // why the signature was not accepted
+}
+
+// verifyPDF checks that the last signature in the PDF in b covers the whole
+// document and was made with the signing certificate, returning the signer
+// subject and signing time.
+func (s *pdfSigner) verifyPDF(b []byte) (signer string, signingTime time.Time, err error) {
+	err = ErrNotSigned
+	if m := pdfByteRangeRx.FindAllSubmatch(b, -1); m != nil {
+		var br [4]int
+		for i := range br {
+			br[i], _ = strconv.Atoi(string(m[len(m)-1][i+1]))
+		}
+		err = ErrSignatureMismatch
+		if br[0] == 0 && br[1] < br[2] && br[2]+br[3] == len(b) && b[br[1]] == '<' && b[br[2]-1] == '>' {
+			var der []byte
+			if der, err = hex.DecodeString(string(b[br[1]+1 : br[2]-1])); err == nil {
+				var rv asn1.RawValue
+				var rest []byte
+				if rest, err = asn1.Unmarshal(der, &rv); err == nil {
+					var p7 *pkcs7.PKCS7
+					if p7, err = pkcs7.Parse(der[:len(der)-len(rest)]); err == nil {
+						p7.Content = append(b[:br[1]:br[1]], b[br[2]:]...)
+						if err = p7.Verify(); err == nil {
+							err = ErrForeignSigner
+							if cert := p7.GetOnlySigner(); cert != nil && bytes.Equal(cert.Raw, s.cert.Raw) {
+								signer = cert.Subject.String()
+								_ = p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningTime, &signingTime)
+								err = nil
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// VerifyPDF checks whether the PDF read from r was produced by this instance, either by
+// its signature or by it being the rinsed PDF of a job still known. The PDF is hashed
+// as it is read, and only kept in memory for the signature check if it is no larger
+// than MaxVerifySignatureSize. Returns an error only if reading from r fails.
+func (rns *Rinse) VerifyPDF(r io.Reader) (v Verification, err error) {
+	h := sha256.New()
+	r = io.TeeReader(r, h)
+	var buf bytes.Buffer
+	if rns.signer != nil {
+		if _, err = io.Copy(&buf, io.LimitReader(r, MaxVerifySignatureSize+1)); err == nil {
+			if buf.Len() > MaxVerifySignatureSize {
+				buf = bytes.Buffer{}
+				v.Error = ErrVerifyTooLarge.Error()
+			}
+		}
+	}
+	if err == nil {
+		if _, err = io.Copy(io.Discard, r); err == nil {
+			v.SHA256 = hex.EncodeToString(h.Sum(nil))
+			if job := rns.findJobOutput(v.SHA256); job != nil {
+				v.Job = job.UUID
+				v.Produced = true
+			}
+			if rns.signer != nil && v.Error == "" {
+				var e error
+				if v.Signer, v.SigningTime, e = rns.signer.verifyPDF(buf.Bytes()); e == nil {
+					v.Signed = true
+					v.Produced = true
+				} else {
+					v.Error = e.Error()
+				}
+			}
+		}
+	}
+	return
+}
+
+// findJobOutput returns the job whose rinsed PDF has the given SHA-256, if any.
+func (rns *Rinse) findJobOutput(sha string) *Job {
+	rns.mu.Lock()
+	defer rns.mu.Unlock()
+	for _, job := range rns.jobs {
+		job.mu.Lock()
+		found := job.OutputSHA256 == sha
+		job.mu.Unlock()
+		if found {
+			return job
+		}
+	}
+	return nil
+}
+
+// SignatureName returns the file name of the detached signature of the rinsed PDF.
+func (job *Job) SignatureName() string {
+	return strings.TrimSuffix(job.ResultName(), ".pdf") + ".p7s"
+}
+
+func (job *Job) SignaturePath() string {
+	return path.Join(job.Datadir, job.SignatureName())
+}
+
+// signOutput signs the rinsed PDF if signing is enabled, unless it came signed from
+// the cache, and writes a detached signature of it. Signing is optional, so a PDF
+// that can't be signed is left as it is and the reason logged.
+func (job *Job) signOutput() (err error) {
+	if s := job.Rinse.signer; s != nil {
+		fpath := job.ResultPath()
+		var b []byte
+		if b, err = os.ReadFile(fpath); err == nil /* #nosec G304 */ {
+			job.mu.Lock()
+			cached := job.Cached
+			job.mu.Unlock()
+			if !cached {
+				if signed, e := s.signPDF(b, "Rinsed"); e == nil {
+					if err = os.WriteFile(fpath, signed, 0644); err == nil /* #nosec G306 */ {
+						b = signed
+					}
+				} else {
+					job.Rinse.Warn("PDF left unsigned", "job", job.Name, "err", e)
+				}
+			}
+			if err == nil {
+				if sig, e := s.detached(b); e == nil {
+					err = os.WriteFile(job.SignaturePath(), sig, 0644) // #nosec G306
+				} else {
+					job.Rinse.Warn("no detached signature", "job", job.Name, "err", e)
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,206 @@
+package rinser
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T) *pdfSigner {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "rinse.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pdfSigner{cert: cert, key: key}
+}
+
+// testPDFObjects are laid out the way tesseract writes its PDF output.
+var testPDFObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [ 3 0 R ] /Count 1 >>",
+	"<< /Type /Page /Parent 2 0 R /MediaBox [ 0 0 612 792 ] /Contents 4 0 R /Resources << >> >>",
+	"<< /Length 9 >>\nstream\nq 0 0 m Q\nendstream",
+	"<< /Producer (Tesseract 5.3.4) /CreationDate (D:20240101120000Z) >>",
+}
+
+// testPDF returns a single page PDF ending in either a cross-reference
+// table or a cross-reference stream.
+func testPDF(xrefStream bool) []byte {
+	return testPDFOf(testPDFObjects, xrefStream)
+}
+
+// testPDFOf returns a PDF of objects numbered from 1, the catalog first
+// and the document information fifth.
+func testPDFOf(objects []string, xrefStream bool) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.5\n%\xDE\xAD\xBE\xEF\n")
+	offsets := []int{0}
+	for i, obj := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	id := "/ID [ <00112233445566778899AABBCCDDEEFF> <00112233445566778899AABBCCDDEEFF> ]"
+	if xrefStream {
+		xrefNum := len(offsets)
+		offsets = append(offsets, buf.Len())
+		var rows bytes.Buffer
+		for i, off := range offsets {
+			typ, gen := byte(1), uint16(0)
+			if i == 0 {
+				typ, gen = 0, 65535
+			}
+			rows.WriteByte(typ)
+			_ = binary.Write(&rows, binary.BigEndian, uint32(off)) // #nosec G115
+			_ = binary.Write(&rows, binary.BigEndian, gen)
+		}
+		fmt.Fprintf(&buf, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Root 1 0 R /Info 5 0 R %s /Length %d >>\nstream\n",
+			xrefNum, len(offsets), id, rows.Len())
+		buf.Write(rows.Bytes())
+		fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", offsets[xrefNum])
+	} else {
+		xref := buf.Len()
+		fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets))
+		for _, off := range offsets[1:] {
+			fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+		}
+		fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(offsets), id, xref)
+	}
+	return buf.Bytes()
+}
+
+func TestSignPDFRoundTrip(t *testing.T) {
+	s := newTestSigner(t)
+	for _, xrefStream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("xrefstream=%v", xrefStream), func(t *testing.T) {
+			b := testPDF(xrefStream)
+			signed, err := s.signPDF(b, "Rinsed")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(signed, b) {
+				t.Fatal("signature not added as an incremental update")
+			}
+			before, err := readTrailer(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := readTrailer(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if before.isStream != xrefStream || after.isStream != xrefStream {
+				t.Errorf("isStream %v then %v, want %v", before.isStream, after.isStream, xrefStream)
+			}
+			if after.root != before.root || after.size <= before.size {
+				t.Errorf("trailer %+v after %+v", after, before)
+			}
+			if after.extra != before.extra || !strings.Contains(after.extra, "/Info 5 0 R") {
+				t.Errorf("Info and ID not carried over: %q", after.extra)
+			}
+			signer, signingTime, err := s.verifyPDF(signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(signer, "rinse.example.com") {
+				t.Errorf("signer %q", signer)
+			}
+			if time.Since(signingTime) > time.Minute {
+				t.Errorf("signing time %v", signingTime)
+			}
+		})
+	}
+}
+
+func TestVerifyPDFTampered(t *testing.T) {
+	s := newTestSigner(t)
+	signed, err := s.signPDF(testPDF(false), "Rinsed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Clone(signed)
+	i := bytes.Index(tampered, []byte("q 0 0 m Q"))
+	tampered[i] = 'Q'
+	if _, _, err = s.verifyPDF(tampered); err == nil {
+		t.Error("changed content verified")
+	}
+
+	appended := append(bytes.Clone(signed), "1 0 obj\n<< >>\nendobj\n"...)
+	if _, _, err = s.verifyPDF(appended); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("appended content: %v", err)
+	}
+
+	if _, _, err = newTestSigner(t).verifyPDF(signed); !errors.Is(err, ErrForeignSigner) {
+		t.Errorf("other certificate: %v", err)
+	}
+
+	if _, _, err = s.verifyPDF(testPDF(false)); !errors.Is(err, ErrNotSigned) {
+		t.Errorf("unsigned: %v", err)
+	}
+}
+
+func TestReadTrailerMalformed(t *testing.T) {
+	for _, tail := range []string{"-5", "99999999", "x"} {
+		b := append(testPDF(false), "startxref\n"+tail+"\n%%EOF\n"...)
+		if _, err := readTrailer(b); err == nil {
+			t.Errorf("startxref %s: no error", tail)
+		}
+	}
+}
+
+func TestSignPDFUnsupported(t *testing.T) {
+	s := newTestSigner(t)
+	b := bytes.Replace(testPDF(false), []byte("/Type /Catalog"), []byte("/Type /Catalog /AcroForm 9 0 R"), 1)
+	if _, err := s.signPDF(b, "Rinsed"); !errors.Is(err, ErrSignUnsupported) {
+		t.Errorf("AcroForm: %v", err)
+	}
+}
+
+func TestRinseVerifyPDF(t *testing.T) {
+	s := newTestSigner(t)
+	rns := &Rinse{signer: s}
+	signed, err := s.signPDF(testPDF(true), "Rinsed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := rns.VerifyPDF(bytes.NewReader(signed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Signed || !v.Produced || v.Error != "" || len(v.SHA256) != 64 {
+		t.Errorf("%+v", v)
+	}
+	v, err = rns.VerifyPDF(bytes.NewReader(testPDF(true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Signed || v.Produced || v.Error != ErrNotSigned.Error() {
+		t.Errorf("%+v", v)
+	}
+}
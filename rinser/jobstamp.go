@@ -0,0 +1,539 @@
+package rinser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+var ErrIllegalStampTemplate = errors.New("illegal stamp template")
+var ErrIllegalStampPosition = errors.New("illegal stamp position")
+var ErrIllegalStamp = errors.New("illegal stamp option")
+var ErrStampUnsupported = errors.New("PDF structure not supported for stamping")
+
+// Stamp positions on the page.
+const (
+	StampFooter    = "footer"
+	StampHeader    = "header"
+	StampWatermark = "watermark"
+)
+
+var StampPositions = []string{StampFooter, StampHeader, StampWatermark}
+
+// DefaultStampTemplate is used when no stamp template is configured.
+const DefaultStampTemplate = `Rinsed {{.Date.Format "2006-01-02 15:04 MST"}} | {{.Filename}} | {{.User}} | {{.UUID}}`
+
+// StampSettings configures the text stamped on each page of rinsed documents.
+type StampSettings struct {
+	Enabled  bool   // stamp jobs that don't opt in or out
+	Template string // text/template given a StampData
+	Position string // one of StampPositions
+}
+
+// StampData is what a stamp template is executed with.
+type StampData struct {
+	Date     time.Time // when the job was added
+	UUID     uuid.UUID
+	Filename string // of the original document
+	User     string // email of the user who added the job
+}
+
+var stampFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(goregular.TTF)
+})
+
+func parseStampTemplate(s string) (tmpl *template.Template, err error) {
+	if tmpl, err = template.New("stamp").Parse(s); err == nil {
+		err = tmpl.Execute(&strings.Builder{}, StampData{})
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrIllegalStampTemplate, err)
+	}
+	return
+}
+
+// CheckStampSettings returns an error if the template is empty or doesn't parse, or the position is unknown.
+func CheckStampSettings(ss StampSettings) (err error) {
+	if strings.TrimSpace(ss.Template) == "" {
+		err = fmt.Errorf("%w: empty", ErrIllegalStampTemplate)
+	} else if _, err = parseStampTemplate(ss.Template); err == nil {
+		if !slices.Contains(StampPositions, ss.Position) {
+			err = fmt.Errorf("%w: %q", ErrIllegalStampPosition, ss.Position)
+		}
+	}
+	return
+}
+
+// ParseStamp returns nil if s is empty, meaning the admin default applies,
+// or whether the job opts in to or out of stamping.
+func ParseStamp(s string) (stamp *bool, err error) {
+	if s = strings.TrimSpace(s); s != "" {
+		var v bool
+		if v, err = strconv.ParseBool(s); err == nil {
+			stamp = &v
+		} else {
+			err = fmt.Errorf("%w: %q", ErrIllegalStamp, s)
+		}
+	}
+	return
+}
+
+func (rns *Rinse) StampSettings() (ss StampSettings) {
+	rns.mu.Lock()
+	ss = rns.stamp
+	rns.mu.Unlock()
+	return
+}
+
+// SetStampSettings sets the stamp settings if they are valid.
+func (rns *Rinse) SetStampSettings(ss StampSettings) (err error) {
+	if err = CheckStampSettings(ss); err == nil {
+		rns.mu.Lock()
+		rns.stamp = ss
+		rns.mu.Unlock()
+	}
+	return
+}
+
+// stamped returns true if the pages of the job are to be stamped.
+func (job *Job) stamped() (yes bool) {
+	yes = job.Rinse.StampSettings().Enabled
+	job.mu.Lock()
+	if job.Stamp != nil {
+		yes = *job.Stamp
+	}
+	job.mu.Unlock()
+	return
+}
+
+// stampText returns the stamp text for the job.
+func (job *Job) stampText(tmplText string) (s string, err error) {
+	var tmpl *template.Template
+	if tmpl, err = parseStampTemplate(tmplText); err == nil {
+		var sb strings.Builder
+		if err = tmpl.Execute(&sb, StampData{
+			Date:     job.Created,
+			UUID:     job.UUID,
+			Filename: job.DocumentName(),
+			User:     job.Email,
+		}); err == nil {
+			s = strings.Join(strings.Fields(sb.String()), " ")
+		}
+	}
+	return
+}
+
+func newStampFace(size float64) (face font.Face, err error) {
+	var f *opentype.Font
+	if f, err = stampFont(); err == nil {
+		face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	}
+	return
+}
+
+// drawStamp draws text onto img, scaled to the image and centered at position.
+func drawStamp(img draw.Image, text, position string) (err error) {
+	r := img.Bounds()
+	size := float64(r.Dy()) / 80
+	if position == StampWatermark {
+		size = float64(r.Dy()) / 20
+	}
+	var face font.Face
+	if face, err = newStampFace(size); err == nil {
+		width := font.MeasureString(face, text).Ceil()
+		if maxWidth := r.Dx() * 9 / 10; width > maxWidth && width > 0 {
+			if face, err = newStampFace(size * float64(maxWidth) / float64(width)); err != nil {
+				return
+			}
+			width = font.MeasureString(face, text).Ceil()
+		}
+		m := face.Metrics()
+		ascent, descent := m.Ascent.Ceil(), m.Descent.Ceil()
+		margin := ascent
+		x := r.Min.X + (r.Dx()-width)/2
+		var y int
+		var ink image.Image = image.NewUniform(color.Gray{Y: 0x30})
+		switch position {
+		case StampHeader:
+			y = r.Min.Y + margin + ascent
+		case StampWatermark:
+			y = r.Min.Y + (r.Dy()+ascent-descent)/2
+			ink = image.NewUniform(color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0x60})
+		default:
+			y = r.Max.Y - margin - descent
+		}
+		if position != StampWatermark {
+			pad := descent
+			band := image.Rect(x-pad, y-ascent-pad, x+width+pad, y+descent+pad).Intersect(r)
+			draw.Draw(img, band, image.White, image.Point{}, draw.Src)
+		}
+		d := font.Drawer{Dst: img, Src: ink, Face: face, Dot: fixed.P(x, y)}
+		d.DrawString(text)
+	}
+	return
+}
+
+// pngChunk returns the chunk of type typ in the PNG data b, including
+// its length, type and CRC, or nil if it comes after the image data or is missing.
+func pngChunk(b []byte, typ string) []byte {
+	for i := 8; i+12 <= len(b); {
+		n := int(binary.BigEndian.Uint32(b[i:]))
+		end := i + 12 + n
+		if n < 0 || end > len(b) {
+			break
+		}
+		switch string(b[i+4 : i+8]) {
+		case typ:
+			return b[i:end]
+		case "IDAT":
+			return nil
+		}
+		i = end
+	}
+	return nil
+}
+
+// stampedExt is added to the name of a page image to name its stamped copy.
+// Tesseract reads the unstamped page, so the stamp is not part of the OCR
+// text, and the stamped copy then replaces the page image in the rinsed PDF.
+const stampedExt = ".stamped"
+
+// stampPage writes the PNG page image at srcPath with text drawn onto it
+// to dstPath, replacing it atomically. The resolution recorded in the image is kept.
+func stampPage(srcPath, dstPath, text, position string) (err error) {
+	var b []byte
+	if b, err = os.ReadFile(srcPath); err == nil /* #nosec G304 */ {
+		var src image.Image
+		if src, err = png.Decode(bytes.NewReader(b)); err == nil {
+			var dst draw.Image
+			switch src.(type) {
+			case *image.Gray, *image.Gray16:
+				dst = image.NewGray(src.Bounds())
+			default:
+				dst = image.NewRGBA(src.Bounds())
+			}
+			draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+			if err = drawStamp(dst, text, position); err == nil {
+				var buf bytes.Buffer
+				if err = png.Encode(&buf, dst); err == nil {
+					out := buf.Bytes()
+					const ihdrEnd = 8 + 12 + 13
+					if phys := pngChunk(b, "pHYs"); phys != nil && len(out) > ihdrEnd && string(out[12:16]) == "IHDR" {
+						out = slices.Concat(out[:ihdrEnd], phys, out[ihdrEnd:])
+					}
+					tmppath := dstPath + ".tmp"
+					if err = os.WriteFile(tmppath, out, 0644); err == nil /* #nosec G306 */ {
+						err = os.Rename(tmppath, dstPath)
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+// runStamp writes a stamped copy of each page image if the job is stamped.
+// The page images themselves are left as they are, so a retry stamps them once.
+func (job *Job) runStamp(ctx context.Context) (err error) {
+	if job.stamped() {
+		ss := job.Rinse.StampSettings()
+		var text string
+		if text, err = job.stampText(ss.Template); err == nil && text != "" {
+			for _, fn := range job.pageFiles() {
+				if err = ctx.Err(); err == nil {
+					fpath := path.Join(job.Datadir, fn)
+					err = stampPage(fpath, fpath+stampedExt, text, ss.Position)
+				}
+				if err != nil {
+					return
+				}
+				job.madeProgress()
+			}
+		}
+	}
+	return
+}
+
+var (
+	pdfPagesRx   = regexp.MustCompile(`/Pages\s+(\d+)\s+(\d+)\s+R`)
+	pdfKidsRx    = regexp.MustCompile(`/Kids\s*\[([^\]]*)\]`)
+	pdfRefRx     = regexp.MustCompile(`(\d+)\s+(\d+)\s+R`)
+	pdfXObjectRx = regexp.MustCompile(`/[^\s/<>\[\]()]+\s+(\d+)\s+(\d+)\s+R`)
+)
+
+type pdfRef struct {
+	num int
+	gen int
+}
+
+// pdfRefOf returns the reference matched by one of the regular expressions above.
+func pdfRefOf(m [][]byte) (ref pdfRef) {
+	ref.num, _ = strconv.Atoi(string(m[1]))
+	ref.gen, _ = strconv.Atoi(string(m[2]))
+	return
+}
+
+// pdfDictAt returns the dictionary that b starts with, or nil if it doesn't start with one.
+func pdfDictAt(b []byte) []byte {
+	b = bytes.TrimLeft(b, " \t\r\n")
+	if bytes.HasPrefix(b, []byte("<<")) {
+		depth := 0
+		for i := 0; i+1 < len(b); i++ {
+			switch {
+			case b[i] == '<' && b[i+1] == '<':
+				depth++
+				i++
+			case b[i] == '>' && b[i+1] == '>':
+				depth--
+				i++
+				if depth == 0 {
+					return b[:i+1]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// pdfObjects finds the objects of a PDF that has a single cross-reference table,
+// the way tesseract and pdfunite write them.
+type pdfObjects struct {
+	b       []byte
+	offsets map[int]int
+}
+
+func readPdfObjects(b []byte) (p pdfObjects, t pdfTrailer, err error) {
+	if t, err = readTrailer(b); err == nil {
+		err = ErrStampUnsupported
+		if table := b[t.xref:]; !t.isStream && bytes.HasPrefix(table, []byte("xref")) {
+			if i := bytes.Index(table, []byte("trailer")); i >= 0 {
+				p = pdfObjects{b: b, offsets: map[int]int{}}
+				fields := strings.Fields(string(table[len("xref"):i]))
+				for len(fields) >= 2 {
+					start, e1 := strconv.Atoi(fields[0])
+					count, e2 := strconv.Atoi(fields[1])
+					if e1 != nil || e2 != nil || count < 0 || len(fields) < 2+3*count {
+						return
+					}
+					for n := range count {
+						entry := fields[2+3*n : 5+3*n]
+						if off, e := strconv.Atoi(entry[0]); e == nil && entry[2] == "n" && off >= 0 && off < len(b) {
+							p.offsets[start+n] = off
+						}
+					}
+					fields = fields[2+3*count:]
+				}
+				if len(fields) == 0 {
+					err = nil
+				}
+			}
+		}
+	}
+	return
+}
+
+// dict returns the dictionary of the object ref, or nil if it isn't one.
+func (p pdfObjects) dict(ref pdfRef) []byte {
+	if off, ok := p.offsets[ref.num]; ok {
+		prefix := fmt.Sprintf("%d %d obj", ref.num, ref.gen)
+		if bytes.HasPrefix(p.b[off:], []byte(prefix)) {
+			return pdfDictAt(p.b[off+len(prefix):])
+		}
+	}
+	return nil
+}
+
+// entry returns the dictionary that is the value of key in dict,
+// reading it from its own object if it is a reference.
+func (p pdfObjects) entry(dict []byte, key string) []byte {
+	if i := bytes.Index(dict, []byte("/"+key)); i >= 0 {
+		v := dict[i+1+len(key):]
+		if m := pdfRefRx.FindSubmatch(v); m != nil && bytes.HasPrefix(bytes.TrimSpace(v), m[0]) {
+			return p.dict(pdfRefOf(m))
+		}
+		return pdfDictAt(v)
+	}
+	return nil
+}
+
+// pageImages appends the image drawn on each page in the page tree node ref to images.
+func (p pdfObjects) pageImages(ref pdfRef, images []pdfRef, depth int) ([]pdfRef, error) {
+	node := p.dict(ref)
+	if node == nil || depth > 32 {
+		return nil, fmt.Errorf("%w: page object %d", ErrStampUnsupported, ref.num)
+	}
+	if kids := pdfKidsRx.FindSubmatch(node); kids != nil {
+		var err error
+		for _, m := range pdfRefRx.FindAllSubmatch(kids[1], -1) {
+			if images, err = p.pageImages(pdfRefOf(m), images, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return images, nil
+	}
+	xobjects := pdfXObjectRx.FindAllSubmatch(p.entry(p.entry(node, "Resources"), "XObject"), -1)
+	if len(xobjects) != 1 {
+		return nil, fmt.Errorf("%w: page object %d has %d images", ErrStampUnsupported, ref.num, len(xobjects))
+	}
+	return append(images, pdfRefOf(xobjects[0])), nil
+}
+
+// pdfImage returns the PNG image at fpath as the entries of an image
+// XObject dictionary and its Flate compressed samples.
+func pdfImage(fpath string) (entries string, data []byte, err error) {
+	var f *os.File
+	if f, err = os.Open(fpath); err == nil /* #nosec G304 */ {
+		defer f.Close()
+		var img image.Image
+		if img, err = png.Decode(f); err == nil {
+			r := img.Bounds()
+			colorSpace, channels := "/DeviceRGB", 3
+			var rows func(y int) []byte
+			if gray, ok := img.(*image.Gray); ok {
+				colorSpace, channels = "/DeviceGray", 1
+				rows = func(y int) []byte { return gray.Pix[gray.PixOffset(r.Min.X, y):gray.PixOffset(r.Max.X, y)] }
+			} else {
+				rgba := image.NewRGBA(r)
+				draw.Draw(rgba, r, img, r.Min, draw.Src)
+				row := make([]byte, channels*r.Dx())
+				rows = func(y int) []byte {
+					pix := rgba.Pix[rgba.PixOffset(r.Min.X, y):]
+					for x := range r.Dx() {
+						copy(row[3*x:3*x+3], pix[4*x:4*x+3])
+					}
+					return row
+				}
+			}
+			var buf bytes.Buffer
+			zw := zlib.NewWriter(&buf)
+			for y := r.Min.Y; y < r.Max.Y && err == nil; y++ {
+				_, err = zw.Write(rows(y))
+			}
+			if e := zw.Close(); err == nil {
+				err = e
+			}
+			entries = fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /FlateDecode",
+				r.Dx(), r.Dy(), colorSpace)
+			data = buf.Bytes()
+		}
+	}
+	return
+}
+
+// stampPDF writes the PDF in b to w with the image on each page replaced
+// by the PNG image named in stamped, as an incremental update.
+// Pages with an empty name in stamped are left as they are.
+func stampPDF(ctx context.Context, w io.Writer, b []byte, stamped []string) (err error) {
+	var p pdfObjects
+	var t pdfTrailer
+	if p, t, err = readPdfObjects(b); err == nil {
+		err = ErrStampUnsupported
+		if m := pdfPagesRx.FindSubmatch(p.dict(pdfRef{t.root, t.rootGen})); m != nil {
+			var images []pdfRef
+			if images, err = p.pageImages(pdfRefOf(m), nil, 0); err == nil {
+				if len(images) != len(stamped) {
+					return fmt.Errorf("%w: %d pages, %d page images", ErrStampUnsupported, len(images), len(stamped))
+				}
+				if _, err = w.Write(b); err != nil {
+					return
+				}
+				written := len(b)
+				var buf bytes.Buffer
+				if !bytes.HasSuffix(b, []byte("\n")) {
+					buf.WriteByte('\n')
+				}
+				offsets := map[pdfRef]int{}
+				for i, fpath := range stamped {
+					if fpath != "" {
+						if err = ctx.Err(); err == nil {
+							var entries string
+							var data []byte
+							if entries, data, err = pdfImage(fpath); err == nil {
+								offsets[images[i]] = written + buf.Len()
+								fmt.Fprintf(&buf, "%d %d obj\n<< %s /Length %d >>\nstream\n", images[i].num, images[i].gen, entries, len(data))
+								buf.Write(data)
+								buf.WriteString("\nendstream\nendobj\n")
+								var n int64
+								n, err = buf.WriteTo(w)
+								written += int(n)
+							}
+						}
+						if err != nil {
+							return
+						}
+					}
+				}
+				xref := written + buf.Len()
+				buf.WriteString("xref\n")
+				for _, ref := range images {
+					if off, ok := offsets[ref]; ok {
+						fmt.Fprintf(&buf, "%d 1\n%010d %05d n\r\n", ref.num, off, ref.gen)
+						delete(offsets, ref)
+					}
+				}
+				fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d %d R /Prev %d%s >>\nstartxref\n%d\n%%%%EOF\n",
+					t.size, t.root, t.rootGen, t.xref, t.extra, xref)
+				_, err = buf.WriteTo(w)
+			}
+		}
+	}
+	return
+}
+
+// embedStampedPages replaces the page images in "output.pdf"
+// with their stamped copies, if the pages were stamped.
+func (job *Job) embedStampedPages(ctx context.Context) (err error) {
+	var stamped []string
+	found := false
+	for _, fn := range job.pageFiles() {
+		fpath := path.Join(job.Datadir, fn+stampedExt)
+		if !fileExists(fpath) {
+			fpath = ""
+		}
+		found = found || fpath != ""
+		stamped = append(stamped, fpath)
+	}
+	if found {
+		fpath := path.Join(job.Datadir, "output.pdf")
+		var b []byte
+		if b, err = os.ReadFile(fpath); err == nil /* #nosec G304 */ {
+			tmppath := fpath + ".tmp"
+			var f *os.File
+			if f, err = os.Create(tmppath); err == nil /* #nosec G304 */ {
+				err = stampPDF(ctx, f, b, stamped)
+				if e := f.Close(); err == nil {
+					err = e
+				}
+				if err == nil {
+					err = os.Rename(tmppath, fpath)
+				} else {
+					_ = os.Remove(tmppath)
+				}
+			}
+		}
+	}
+	return
+}
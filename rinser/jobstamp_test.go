@@ -0,0 +1,225 @@
+package rinser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func writeTestPage(t *testing.T, fpath string) {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 400, 300))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fpath, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunStamp(t *testing.T) {
+	dir := t.TempDir()
+	pages := []string{"page-1.png", "page-2.png"}
+	job := &Job{
+		Rinse:    &Rinse{stamp: StampSettings{Enabled: true, Template: DefaultStampTemplate, Position: StampWatermark}},
+		Name:     "doc.pdf",
+		UUID:     uuid.New(),
+		Created:  time.Now(),
+		Datadir:  dir,
+		docName:  "doc.pdf",
+		imgfiles: map[string]bool{},
+	}
+	for _, fn := range pages {
+		writeTestPage(t, path.Join(dir, fn))
+		job.imgfiles[fn] = false
+	}
+	text, err := job.stampText(DefaultStampTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refPath := path.Join(t.TempDir(), "ref.png")
+	writeTestPage(t, refPath)
+	unstamped, err := os.ReadFile(refPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = stampPage(refPath, refPath, text, StampWatermark); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(refPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second run is a retry
+	for range 2 {
+		if err = job.runStamp(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, fn := range pages {
+		for name, wantData := range map[string][]byte{fn: unstamped, fn + stampedExt: want} {
+			got, err := os.ReadFile(path.Join(dir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, wantData) {
+				t.Errorf("%s differs", name)
+			}
+		}
+	}
+}
+
+func TestCheckStampSettings(t *testing.T) {
+	for _, tmpl := range []string{"", " \n", "{{.Nope}}", "{{"} {
+		if err := CheckStampSettings(StampSettings{Template: tmpl, Position: StampFooter}); !errors.Is(err, ErrIllegalStampTemplate) {
+			t.Errorf("template %q: %v", tmpl, err)
+		}
+	}
+	if err := CheckStampSettings(StampSettings{Template: DefaultStampTemplate, Position: "side"}); !errors.Is(err, ErrIllegalStampPosition) {
+		t.Errorf("position: %v", err)
+	}
+	if err := CheckStampSettings(StampSettings{Template: DefaultStampTemplate, Position: StampHeader}); err != nil {
+		t.Error(err)
+	}
+}
+
+// testStampPDFObjects are two pages in a nested page tree, the second
+// with its resources in an object of their own.
+var testStampPDFObjects = []string{
+	"<< /Type /Catalog /Pages 2 0 R >>",
+	"<< /Type /Pages /Kids [ 3 0 R 8 0 R ] /Count 2 >>",
+	"<< /Type /Page /Parent 2 0 R /MediaBox [ 0 0 612 792 ] /Contents 4 0 R /Resources << /XObject << /Im1 6 0 R >> /ProcSet [ /PDF /ImageB ] >> >>",
+	"<< /Length 9 >>\nstream\nq 0 0 m Q\nendstream",
+	"<< /Producer (Tesseract 5.3.4) /CreationDate (D:20240101120000Z) >>",
+	"<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Length 1 >>\nstream\n\xFF\nendstream",
+	"<< /XObject << /Im1 9 0 R >> >>",
+	"<< /Type /Pages /Parent 2 0 R /Kids [ 10 0 R ] /Count 1 >>",
+	"<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Length 1 >>\nstream\n\xFF\nendstream",
+	"<< /Type /Page /Parent 8 0 R /MediaBox [ 0 0 612 792 ] /Contents 4 0 R /Resources 7 0 R >>",
+}
+
+// readTestImage returns the dictionary and decompressed samples of the image object num.
+func readTestImage(t *testing.T, p pdfObjects, num int) (dict string, samples []byte) {
+	t.Helper()
+	d := p.dict(pdfRef{num, 0})
+	if d == nil {
+		t.Fatalf("image %d not replaced", num)
+	}
+	off := p.offsets[num]
+	data := p.b[off+bytes.Index(p.b[off:], []byte("stream\n"))+len("stream\n"):]
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if samples, err = io.ReadAll(zr); err != nil {
+		t.Fatal(err)
+	}
+	return string(d), samples
+}
+
+func TestStampPDF(t *testing.T) {
+	dir := t.TempDir()
+	grayPath := path.Join(dir, "gray.png")
+	writeTestPage(t, grayPath)
+	if err := stampPage(grayPath, grayPath, "Rinsed", StampFooter); err != nil {
+		t.Fatal(err)
+	}
+	colorPath := path.Join(dir, "color.png")
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 0xFF, A: 0xFF}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(colorPath, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	b := testPDFOf(testStampPDFObjects, false)
+	var out bytes.Buffer
+	if err := stampPDF(context.Background(), &out, b, []string{colorPath, grayPath}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), b) {
+		t.Fatal("images not replaced as an incremental update")
+	}
+	before, err := readTrailer(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, after, err := readPdfObjects(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.root != before.root || after.size != before.size || after.extra != before.extra {
+		t.Errorf("trailer %+v after %+v", after, before)
+	}
+	if !bytes.Contains(out.Bytes()[after.xref:], fmt.Appendf(nil, "/Prev %d ", before.xref)) {
+		t.Error("no /Prev to the original cross-reference table")
+	}
+
+	dict, samples := readTestImage(t, p, 6)
+	if !strings.Contains(dict, "/Width 3 /Height 2 /ColorSpace /DeviceRGB") {
+		t.Errorf("color image %s", dict)
+	}
+	if !bytes.Equal(samples, bytes.Repeat([]byte{0xFF, 0, 0}, 6)) {
+		t.Errorf("color samples %x", samples)
+	}
+	dict, samples = readTestImage(t, p, 9)
+	if !strings.Contains(dict, "/Width 400 /Height 300 /ColorSpace /DeviceGray") {
+		t.Errorf("gray image %s", dict)
+	}
+	f, err := os.Open(grayPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stamped, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gray, ok := stamped.(*image.Gray); !ok || !bytes.Equal(samples, gray.Pix) {
+		t.Error("gray samples differ from the stamped page")
+	}
+
+	out.Reset()
+	if err = stampPDF(context.Background(), &out, b, []string{"", grayPath}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _, err = readPdfObjects(out.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.offsets[6]; ok {
+		t.Error("unstamped page image replaced")
+	}
+
+	for name, tc := range map[string]struct {
+		b       []byte
+		stamped []string
+	}{
+		"page count":        {b, []string{grayPath}},
+		"cross-ref stream":  {testPDFOf(testStampPDFObjects, true), []string{"", grayPath}},
+		"image not on page": {testPDF(false), []string{grayPath}},
+	} {
+		if err = stampPDF(context.Background(), io.Discard, tc.b, tc.stamped); !errors.Is(err, ErrStampUnsupported) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package rinser
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrJobInterrupted = errors.New("job interrupted by restart")
+
+// persistedState is a JobState stored by its stage ID, so that records
+// stay valid when stages are added.
+type persistedState JobState
+
+func (ps persistedState) MarshalJSON() ([]byte, error) {
+	if id, ok := stateID(JobState(ps)); ok {
+		return json.Marshal(id)
+	}
+	return json.Marshal(int(ps))
+}
+
+func (ps *persistedState) UnmarshalJSON(b []byte) (err error) {
+	var id string
+	if err = json.Unmarshal(b, &id); err == nil {
+		var state JobState
+		state, err = idState(id)
+		*ps = persistedState(state)
+	}
+	return
+}
+
+// jobRecord is the persisted form of a Job.
+type jobRecord struct {
+	UUID          uuid.UUID
+	Name          string
+	Created       time.Time
+	MaxSizeMB     int
+	MaxTimeSec    int
+	CleanupSec    int
+	TimeoutSec    int
+	CleanupGotten bool
+	Private       bool
+	Email         string
+	Parent        uuid.UUID
+	Depth         int
+	Batch         uuid.UUID
+	Callback      string
+	Formats       []string
+	Dpi           int
+	ColorMode     string
+	PageRanges    string
+	Stamp         *bool
+	Children      int
+	History       []StageRecord
+	Cached        bool
+	CacheKey      string
+	Verdict       string
+	Malware       string
+	Quarantined   bool
+	Risk          *Risk
+	Error         string
+	PdfName       string
+	MimeType      string
+	InputSHA256   string
+	OutputSHA256  string
+	OutputHashes  map[string]string
+	Language      string
+	Done          bool
+	Diskuse       int64
+	Pages         int
+	TotalPages    int
+	Downloads     int
+	Retries       int
+	ManualRetries int
+	Priority      int
+	QueueSeq      int
+	RetryAt       time.Time
+	Resume        persistedState
+	Started       time.Time
+	Stopped       time.Time
+	DocName       string
+	WorkExt       string
+	State         persistedState
+	ErrState      persistedState
+}
+
+func (rns *Rinse) JobsDir() string {
+	return path.Join(rns.Config.DataDir, "jobs")
+}
+
+func jobWorkdir(id uuid.UUID) string {
+	return path.Join(os.TempDir(), "rinse-"+id.String())
+}
+
+func (job *Job) recordPath() string {
+	return path.Join(job.Rinse.JobsDir(), job.UUID.String()+".json")
+}
+
+func (job *Job) record() (rec jobRecord) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	rec = jobRecord{
+		UUID:          job.UUID,
+		Name:          job.Name,
+		Created:       job.Created,
+		MaxSizeMB:     job.MaxSizeMB,
+		MaxTimeSec:    job.MaxTimeSec,
+		CleanupSec:    job.CleanupSec,
+		TimeoutSec:    job.TimeoutSec,
+		CleanupGotten: job.CleanupGotten,
+		Private:       job.Private,
+		Email:         job.Email,
+		Parent:        job.Parent,
+		Depth:         job.Depth,
+		Batch:         job.Batch,
+		Callback:      job.Callback,
+		Formats:       job.Formats,
+		Dpi:           job.Dpi,
+		ColorMode:     job.ColorMode,
+		PageRanges:    job.PageRanges,
+		Stamp:         job.Stamp,
+		Children:      job.Children,
+		History:       slices.Clone(job.History),
+		Cached:        job.Cached,
+		CacheKey:      job.cacheKey,
+		Verdict:       job.Verdict,
+		Malware:       job.Malware,
+		Quarantined:   job.Quarantined,
+		Risk:          job.Risk,
+		PdfName:       job.PdfName,
+		MimeType:      job.MimeType,
+		InputSHA256:   job.InputSHA256,
+		OutputSHA256:  job.OutputSHA256,
+		OutputHashes:  job.outputHashes,
+		Language:      job.Language,
+		Done:          job.Done,
+		Diskuse:       job.Diskuse,
+		Pages:         job.Pages,
+		TotalPages:    job.TotalPages,
+		Downloads:     job.Downloads,
+		Retries:       job.Retries,
+		ManualRetries: job.ManualRetries,
+		Priority:      job.Priority,
+		QueueSeq:      job.queueSeq,
+		RetryAt:       job.retryAt,
+		Resume:        persistedState(job.resume),
+		Started:       job.started,
+		Stopped:       job.stopped,
+		DocName:       job.docName,
+		WorkExt:       job.workExt,
+		State:         persistedState(job.state),
+		ErrState:      persistedState(job.errstate),
+	}
+	if job.Error != nil {
+		rec.Error = job.Error.Error()
+	}
+	return
+}
+
+// save writes the job record to the job store.
+//
+// Nothing is written once the job is closed or shut down, so jobs
+// that are cancelled by a shutdown keep their last recorded state.
+func (job *Job) save() {
+	job.saveMu.Lock()
+	defer job.saveMu.Unlock()
+	job.mu.Lock()
+	skip := job.closed || job.shutdown
+	job.mu.Unlock()
+	if !skip {
+		b, err := json.MarshalIndent(job.record(), "", " ")
+		if err == nil {
+			fpath := job.recordPath()
+			tmppath := fpath + ".tmp"
+			if err = os.WriteFile(tmppath, b, 0640); err == nil /* #nosec G306 */ {
+				err = os.Rename(tmppath, fpath)
+			}
+		}
+		if err != nil {
+			job.Rinse.Error("job.save", "job", job.Name, "err", err)
+		}
+	}
+}
+
+func (job *Job) deleteRecord() {
+	job.saveMu.Lock()
+	defer job.saveMu.Unlock()
+	if err := os.Remove(job.recordPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		job.Rinse.Error("job.deleteRecord", "job", job.Name, "err", err)
+	}
+}
+
+func (rns *Rinse) jobFromRecord(rec *jobRecord) (job *Job, err error) {
+	workDir := jobWorkdir(rec.UUID)
+	dataDir := path.Join(workDir, "data")
+	if _, err = os.Stat(dataDir); err != nil {
+		if JobState(rec.State) == JobNew && rec.Parent == uuid.Nil && hasHTTPScheme(rec.Name) {
+			if err = os.MkdirAll(dataDir, 0777); err != nil /* #nosec G301 */ {
+				return
+			}
+		} else {
+			return
+		}
+	}
+	job = &Job{
+		Rinse:         rns,
+		Name:          rec.Name,
+		Language:      rec.Language,
+		Workdir:       workDir,
+		Datadir:       dataDir,
+		Created:       rec.Created,
+		UUID:          rec.UUID,
+		MaxSizeMB:     rec.MaxSizeMB,
+		MaxTimeSec:    rec.MaxTimeSec,
+		CleanupSec:    rec.CleanupSec,
+		TimeoutSec:    rec.TimeoutSec,
+		CleanupGotten: rec.CleanupGotten,
+		Private:       rec.Private,
+		Email:         rec.Email,
+		Parent:        rec.Parent,
+		Depth:         rec.Depth,
+		Batch:         rec.Batch,
+		Callback:      rec.Callback,
+		Formats:       rec.Formats,
+		Dpi:           rec.Dpi,
+		ColorMode:     rec.ColorMode,
+		PageRanges:    rec.PageRanges,
+		Stamp:         rec.Stamp,
+		Children:      rec.Children,
+		History:       rec.History,
+		Cached:        rec.Cached,
+		cacheKey:      rec.CacheKey,
+		Verdict:       rec.Verdict,
+		Malware:       rec.Malware,
+		Quarantined:   rec.Quarantined,
+		Risk:          rec.Risk,
+		PdfName:       rec.PdfName,
+		MimeType:      rec.MimeType,
+		InputSHA256:   rec.InputSHA256,
+		OutputSHA256:  rec.OutputSHA256,
+		outputHashes:  rec.OutputHashes,
+		Done:          rec.Done,
+		Diskuse:       rec.Diskuse,
+		Pages:         rec.Pages,
+		TotalPages:    rec.TotalPages,
+		Downloads:     rec.Downloads,
+		Retries:       rec.Retries,
+		ManualRetries: rec.ManualRetries,
+		Priority:      rec.Priority,
+		queueSeq:      rec.QueueSeq,
+		stoppedCh:     make(chan struct{}),
+		started:       rec.Started,
+		stopped:       rec.Stopped,
+		docName:       rec.DocName,
+		workExt:       rec.WorkExt,
+		state:         JobState(rec.State),
+		errstate:      JobState(rec.ErrState),
+		resume:        JobState(rec.Resume),
+		retryAt:       rec.RetryAt,
+		imgfiles:      make(map[string]bool),
+		previews:      make(map[uint64][]byte),
+	}
+	if rec.Error != "" {
+		job.Error = errors.New(rec.Error)
+	}
+	switch job.state {
+	case JobNew:
+	case JobFinished, JobFailed:
+		close(job.stoppedCh)
+	default:
+		// the job was running when we stopped
+		job.errstate = job.state
+		job.state = JobFailed
+		job.Error = ErrJobInterrupted
+		job.stopped = time.Now()
+		job.interruptStageLocked(job.stopped)
+		job.Done = true
+		close(job.stoppedCh)
+		job.scheduleRetry(job.Error)
+	}
+	return
+}
+
+// loadJobs reloads the job store, discarding records whose
+// working directory no longer exists.
+func (rns *Rinse) loadJobs() (err error) {
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(rns.JobsDir()); err == nil {
+		var jobs []*Job
+		for _, de := range entries {
+			fpath := path.Join(rns.JobsDir(), de.Name())
+			if filepath.Ext(de.Name()) != ".json" {
+				_ = os.Remove(fpath)
+				continue
+			}
+			var rec jobRecord
+			b, e := os.ReadFile(fpath) // #nosec G304
+			if e == nil {
+				e = json.Unmarshal(b, &rec)
+			}
+			var job *Job
+			if e == nil {
+				job, e = rns.jobFromRecord(&rec)
+			}
+			if e != nil {
+				rns.Warn("loadJobs: dropping job", "file", fpath, "err", e)
+				_ = os.Remove(fpath)
+				continue
+			}
+			job.refreshDiskuse()
+			job.save()
+			jobs = append(jobs, job)
+		}
+		rns.mu.Lock()
+		rns.jobs = append(rns.jobs, jobs...)
+		rns.mu.Unlock()
+		rns.Info("loadJobs", "count", len(jobs))
+	} else if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+// scrubOrphans removes job working directories that have no job.
+func (rns *Rinse) scrubOrphans() {
+	entries, err := os.ReadDir(os.TempDir())
+	if err == nil {
+		for _, de := range entries {
+			if s, ok := strings.CutPrefix(de.Name(), "rinse-"); ok && de.IsDir() {
+				if id, e := uuid.Parse(s); e == nil && rns.findJobUuid(id) == nil {
+					fpath := path.Join(os.TempDir(), de.Name())
+					if e = scrub(fpath); e == nil {
+						rns.Info("scrubbed orphaned workdir", "workdir", fpath)
+					} else {
+						rns.Error("scrubOrphans", "workdir", fpath, "err", e)
+					}
+				}
+			}
+		}
+	}
+}
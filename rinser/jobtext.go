@@ -0,0 +1,83 @@
+package rinser
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// TextPage is the OCR text of one page of a rinsed document.
+type TextPage struct {
+	Page       int     `json:"page" example:"1"`
+	Text       string  `json:"text" example:"Hello world"`
+	Words      int     `json:"words" example:"2"`
+	Confidence float64 `json:"confidence" example:"94.5"` // mean word confidence, 0 to 100
+}
+
+// Text is the OCR text of a rinsed document, by page.
+type Text struct {
+	Language string     `json:"lang,omitempty" example:"eng"`
+	Pages    []TextPage `json:"pages"`
+}
+
+// splitPages splits tesseract text output at the form feeds that end each page.
+func splitPages(txt string) (pages []string) {
+	pages = strings.Split(txt, "\f")
+	if len(pages) > 1 && strings.TrimSpace(pages[len(pages)-1]) == "" {
+		pages = pages[:len(pages)-1]
+	}
+	return
+}
+
+// tsvConfidences returns the number of words and their mean confidence
+// for each page in tesseract TSV output, indexed by page number less one.
+func tsvConfidences(b []byte) (words []int, confs []float64) {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(nil, 1024*1024)
+	for first := true; sc.Scan(); first = false {
+		// level page_num block_num par_num line_num word_num left top width height conf text
+		if cols := strings.Split(sc.Text(), "\t"); !first && len(cols) == 12 && cols[0] == "5" {
+			page, err1 := strconv.Atoi(cols[1])
+			conf, err2 := strconv.ParseFloat(cols[10], 64)
+			if err1 == nil && err2 == nil && page > 0 && conf >= 0 && strings.TrimSpace(cols[11]) != "" {
+				for len(words) < page {
+					words = append(words, 0)
+					confs = append(confs, 0)
+				}
+				words[page-1]++
+				confs[page-1] += conf
+			}
+		}
+	}
+	for i, n := range words {
+		if n > 0 {
+			confs[i] = math.Round(confs[i]/float64(n)*100) / 100
+		}
+	}
+	return
+}
+
+// Text returns the OCR text of the finished job by page, with the
+// confidence of each page.
+func (job *Job) Text() (text Text, err error) {
+	var txt, tsv []byte
+	if txt, err = os.ReadFile(job.OutputPath("txt")); err == nil /* #nosec G304 */ {
+		if tsv, err = os.ReadFile(job.OutputPath("tsv")); err == nil /* #nosec G304 */ {
+			words, confs := tsvConfidences(tsv)
+			text.Language = job.Lang()
+			text.Pages = []TextPage{}
+			for i, s := range splitPages(string(txt)) {
+				tp := TextPage{Page: i + 1, Text: s}
+				if i < len(words) {
+					tp.Words = words[i]
+					tp.Confidence = confs[i]
+				}
+				text.Pages = append(text.Pages, tp)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,315 @@
+package rinser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrArchiveTooDeep = errors.New("archive nested too deep")
+var ErrArchiveTooManyFiles = errors.New("archive has too many files")
+var ErrArchiveTooLarge = errors.New("archive unpacks too large")
+
+// ArchiveLimits restricts how archives are unpacked.
+type ArchiveLimits struct {
+	MaxFiles int // most files and directories in an archive, zero for no limit
+	MaxRatio int // most unpacked size relative to the archive size, zero for no limit
+	MaxDepth int // levels of archives within archives that are unpacked
+}
+
+// archiveExts are the extensions of archives, including those of single
+// compressed files, which come after the compressed TAR extensions.
+var archiveExts = []string{".zip", ".tar", ".tgz", ".tar.gz", ".tbz2", ".tar.bz2", ".txz", ".tar.xz", ".7z", ".gz", ".bz2", ".xz"}
+
+func isArchiveName(fn string) bool {
+	fn = strings.ToLower(fn)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(fn, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimArchiveExt(fn string) string {
+	lfn := strings.ToLower(fn)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(lfn, ext) {
+			return fn[:len(fn)-len(ext)]
+		}
+	}
+	return fn
+}
+
+// IsArchive returns true if the job is an archive that documents were unpacked from.
+func (job *Job) IsArchive() (yes bool) {
+	job.mu.Lock()
+	yes = job.Children > 0 && isArchiveName(job.docName+job.workExt)
+	job.mu.Unlock()
+	return
+}
+
+// typedName returns the document name with the extension matching
+// its detected type appended, if the name lacks one.
+func (job *Job) typedName() (s string) {
+	job.mu.Lock()
+	s = job.docName + job.workExt
+	job.mu.Unlock()
+	return
+}
+
+func (rns *Rinse) ArchiveLimits() (limits ArchiveLimits) {
+	rns.mu.Lock()
+	limits = rns.archiveLimits
+	rns.mu.Unlock()
+	return
+}
+
+// unpackedUsage returns the number of entries and bytes below dir,
+// without following symlinks.
+func unpackedUsage(dir string) (files int, size int64) {
+	_ = filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+		if err == nil && fpath != dir {
+			files++
+			if d.Type().IsRegular() {
+				if fi, e := d.Info(); e == nil {
+					size += fi.Size()
+				}
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func checkUnpacked(limits ArchiveLimits, files int, size, maxSize int64) error {
+	if limits.MaxFiles > 0 && files > limits.MaxFiles {
+		return fmt.Errorf("%w: more than %d", ErrArchiveTooManyFiles, limits.MaxFiles)
+	}
+	if maxSize > 0 && size > maxSize {
+		return fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, maxSize)
+	}
+	return nil
+}
+
+// watchUnpack cancels the unpacking if it exceeds the limits.
+func (job *Job) watchUnpack(ctx context.Context, cancel context.CancelCauseFunc, dir string, limits ArchiveLimits, maxSize int64) {
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			files, size := unpackedUsage(dir)
+			if err := checkUnpacked(limits, files, size, maxSize); err != nil {
+				cancel(err)
+				return
+			}
+			job.madeProgress()
+		}
+	}
+}
+
+// runUnpack expands archive and email message documents. Each document
+// in an archive, and each attachment of a message, becomes a child job.
+func (job *Job) runUnpack(ctx context.Context) (err error) {
+	var fn string
+	if fn, err = job.DocumentFile(); err == nil {
+		docName := job.typedName()
+		switch {
+		case isArchiveName(docName):
+			err = job.unpackArchive(ctx, fn)
+		case isMessageName(docName):
+			err = job.unpackMessage(ctx, fn)
+		}
+	}
+	return
+}
+
+// Exit codes of unpackScript when the archive exceeds the limits.
+const (
+	unpackExitTooLarge     = 3
+	unpackExitTooManyFiles = 4
+)
+
+// unpackScript expands the archive $1 into /var/rinse/unpacked, but only if it has
+// at most $3 entries and its contents as a tar stream are at most $2 bytes, where
+// zero means no limit. Both are measured without writing anything, and stop reading
+// as soon as the limit is passed, so the sandbox never writes more than the limits.
+// If $4 isn't empty, $1 is a compressed file, which unless it is a TAR archive
+// is decompressed to the file $4 if that is at most $2 bytes.
+const unpackScript = `if [ -n "$4" ] && ! bsdtar -tf "$1" >/dev/null 2>&1; then
+	if [ "$2" -gt 0 ] && [ "$(bsdcat "$1" | head -c $(($2 + 1)) | wc -c)" -gt "$2" ]; then
+		exit 3
+	fi
+	exec bsdcat "$1" >"/var/rinse/unpacked/$4"
+fi
+if [ "$3" -gt 0 ] && [ "$(bsdtar -tf "$1" | head -n $(($3 + 1)) | wc -l)" -gt "$3" ]; then
+	exit 4
+fi
+if [ "$2" -gt 0 ] && [ "$(bsdtar -cf - --format pax @"$1" | head -c $(($2 + 1)) | wc -c)" -gt "$2" ]; then
+	exit 3
+fi
+exec bsdtar -x --no-same-owner --no-same-permissions -C /var/rinse/unpacked -f "$1"`
+
+// decompressedName returns the name of the document in a compressed file,
+// or the empty string if the job document isn't a compressed file.
+func (job *Job) decompressedName() (s string) {
+	name := job.typedName()
+	switch strings.ToLower(path.Ext(name)) {
+	case ".gz", ".bz2", ".xz":
+		if s = trimArchiveExt(path.Base(name)); s == "" || skipUnpacked(s) {
+			s = "document"
+		}
+	}
+	return
+}
+
+// unpackArchive adds a child job for each document in the archive.
+// The archive job itself then finishes without running the remaining stages.
+func (job *Job) unpackArchive(ctx context.Context, fn string) (err error) {
+	if err = job.unpack(ctx, fn, func(maxSize int64, maxFiles int) []string {
+		return []string{"sh", "-c", unpackScript, "unpack", "/var/rinse/" + fn, strconv.FormatInt(maxSize, 10), strconv.Itoa(maxFiles), job.decompressedName()}
+	}); err != nil {
+		var exiterr *exec.ExitError
+		if errors.As(err, &exiterr) {
+			switch exiterr.ExitCode() {
+			case unpackExitTooLarge:
+				err = fmt.Errorf("%w: %w", ErrArchiveTooLarge, err)
+			case unpackExitTooManyFiles:
+				err = fmt.Errorf("%w: %w", ErrArchiveTooManyFiles, err)
+			}
+		}
+	} else {
+		if !job.IsArchive() {
+			return ErrMissingDocument
+		}
+		if err = scrub(path.Join(job.Datadir, fn)); err == nil {
+			job.mu.Lock()
+			job.PdfName = strings.ReplaceAll(trimArchiveExt(job.docName+job.workExt)+"-rinsed.zip", "\"", "")
+			job.mu.Unlock()
+			err = ErrPipelineDone
+		}
+	}
+	return
+}
+
+// unpack runs the command returned by cmds in the sandbox to expand the document fn
+// into the "unpacked" directory within the limits set by ArchiveLimits, and then adds
+// a child job for each file in it. The command is given the limits to enforce within
+// the sandbox, and the unpacked directory is also watched from outside it.
+func (job *Job) unpack(ctx context.Context, fn string, cmds func(maxSize int64, maxFiles int) []string) (err error) {
+	limits := job.Rinse.ArchiveLimits()
+	if job.Depth > limits.MaxDepth {
+		return ErrArchiveTooDeep
+	}
+	if job.HasChildren() {
+		// resumed after the children were added
+		return nil
+	}
+	var fi os.FileInfo
+	if fi, err = os.Stat(path.Join(job.Datadir, fn)); err == nil {
+		maxSize := job.MaxUploadSize()
+		if ratioSize := fi.Size() * int64(limits.MaxRatio); ratioSize > 0 && (maxSize < 1 || ratioSize < maxSize) {
+			maxSize = ratioSize
+		}
+		dir := path.Join(job.Datadir, "unpacked")
+		_ = scrub(dir)
+		if err = os.Mkdir(dir, 0777); err == nil /* #nosec G301 */ {
+			defer scrub(dir)
+			unpackCtx, cancel := context.WithCancelCause(ctx)
+			go job.watchUnpack(unpackCtx, cancel, dir, limits, maxSize)
+			err = job.runsc(unpackCtx, job.madeProgressHandler, cmds(maxSize, limits.MaxFiles)...)
+			if cause := context.Cause(unpackCtx); cause != nil && ctx.Err() == nil {
+				err = cause
+			}
+			cancel(nil)
+			if err == nil {
+				files, size := unpackedUsage(dir)
+				if err = checkUnpacked(limits, files, size, maxSize); err == nil {
+					err = job.addChildJobs(dir)
+				}
+			}
+		}
+	}
+	return
+}
+
+func skipUnpacked(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "__MACOSX"
+}
+
+// addChildJobs moves each document below dir into a new job of its own.
+func (job *Job) addChildJobs(dir string) (err error) {
+	var docs []string
+	err = filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+		if err == nil && fpath != dir {
+			if skipUnpacked(d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+			} else if d.Type().IsRegular() {
+				docs = append(docs, fpath)
+			}
+		}
+		return err
+	})
+	if err == nil {
+		var children []*Job
+		for _, fpath := range docs {
+			var child *Job
+			if child, err = job.newChildJob(dir, fpath); err != nil {
+				break
+			}
+			children = append(children, child)
+		}
+		if err == nil {
+			// all or none, so that a retry doesn't add them twice
+			if err = job.Rinse.AddJobs(children); err == nil {
+				job.mu.Lock()
+				job.Children += len(children)
+				job.mu.Unlock()
+				job.save()
+				return
+			}
+		}
+		for _, child := range children {
+			child.Close(err)
+		}
+	}
+	return
+}
+
+func (job *Job) newChildJob(dir, fpath string) (child *Job, err error) {
+	var rel string
+	if rel, err = filepath.Rel(dir, fpath); err == nil {
+		if child, err = NewJob(job.Rinse, filepath.ToSlash(rel), job.Lang(), job.MaxSizeMB, job.MaxTimeSec,
+			job.CleanupSec, job.TimeoutSec, job.CleanupGotten, job.Private, job.Email); err == nil {
+			child.Parent = job.UUID
+			child.Depth = job.Depth + 1
+			child.Batch = job.Batch
+			child.Callback = job.Callback
+			child.spanContext = job.spanContext
+			child.Formats = job.Formats
+			child.Dpi = job.Dpi
+			child.ColorMode = job.ColorMode
+			child.Stamp = job.Stamp
+			child.Priority = job.GetPriority()
+			if err = os.Rename(fpath, path.Join(child.Datadir, filepath.Base(fpath))); err == nil {
+				return
+			}
+			child.Close(err)
+			child = nil
+		}
+	}
+	return
+}
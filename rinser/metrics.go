@@ -0,0 +1,149 @@
+package rinser
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	metricStageSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rinse_stage_duration_seconds",
+		Help:    "Time jobs spent in each stage.",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 16),
+	}, []string{"stage"})
+	metricFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rinse_job_failures_total",
+		Help: "Failed jobs by the stage they failed in.",
+	}, []string{"errstate"})
+	metricRunscExits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rinse_runsc_exits_total",
+		Help: "Sandbox runs by exit code, -1 if the sandbox did not exit.",
+	}, []string{"code"})
+	metricBytesIn = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rinse_bytes_in_total",
+		Help: "Bytes of documents uploaded or downloaded.",
+	})
+	metricBytesOut = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rinse_bytes_out_total",
+		Help: "Bytes of rinsed documents and other outputs served.",
+	})
+	metricDownloads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rinse_downloads_total",
+		Help: "Rinsed documents downloaded.",
+	})
+	metricScrubErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "rinse_scrub_errors_total",
+		Help: "Scrubs that failed to overwrite or remove a file.",
+	})
+	metricMalware = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rinse_malware_found_total",
+		Help: "Documents found to be malware, by the scan policy applied.",
+	}, []string{"policy"})
+)
+
+var (
+	descJobs      = prometheus.NewDesc("rinse_jobs", "Jobs by state.", []string{"state"}, nil)
+	descQueued    = prometheus.NewDesc("rinse_queue_depth", "Jobs waiting to start.", nil, nil)
+	descDiskuse   = prometheus.NewDesc("rinse_diskuse_bytes", "Disk used by all jobs.", nil, nil)
+	descOcrInUse  = prometheus.NewDesc("rinse_ocr_cpus_in_use", "OCR sandboxes running.", nil, nil)
+	descOcrBudget = prometheus.NewDesc("rinse_ocr_cpus", "OCR CPU budget.", nil, nil)
+)
+
+// metricsCollector collects the metrics that are computed from the job list when scraped.
+type metricsCollector struct{ *Rinse }
+
+// Describe implements prometheus.Collector.
+func (mc metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- descJobs
+	ch <- descQueued
+	ch <- descDiskuse
+	ch <- descOcrInUse
+	ch <- descOcrBudget
+}
+
+// Collect implements prometheus.Collector.
+func (mc metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	mc.mu.Lock()
+	jobs := slices.Clone(mc.jobs)
+	ocrInUse := mc.ocrInUse
+	ocrCPUs := mc.ocrCPUs
+	mc.mu.Unlock()
+	counts := map[string]int{}
+	var queued int
+	var diskuse int64
+	for _, job := range jobs {
+		job.mu.Lock()
+		state := job.state
+		diskuse += job.Diskuse
+		job.mu.Unlock()
+		if state == JobNew {
+			queued++
+		}
+		counts[jobStateText(state)]++
+	}
+	for state, n := range counts {
+		ch <- prometheus.MustNewConstMetric(descJobs, prometheus.GaugeValue, float64(n), state)
+	}
+	ch <- prometheus.MustNewConstMetric(descQueued, prometheus.GaugeValue, float64(queued))
+	ch <- prometheus.MustNewConstMetric(descDiskuse, prometheus.GaugeValue, float64(diskuse))
+	ch <- prometheus.MustNewConstMetric(descOcrInUse, prometheus.GaugeValue, float64(ocrInUse))
+	ch <- prometheus.MustNewConstMetric(descOcrBudget, prometheus.GaugeValue, float64(ocrCPUs))
+}
+
+func (rns *Rinse) newMetricsHandler() http.Handler {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metricStageSeconds,
+		metricFailures,
+		metricRunscExits,
+		metricBytesIn,
+		metricBytesOut,
+		metricDownloads,
+		metricScrubErrors,
+		metricMalware,
+		metricsCollector{rns},
+	)
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
+}
+
+func observeRunscExit(code int) {
+	metricRunscExits.WithLabelValues(strconv.Itoa(code)).Inc()
+}
+
+// MetricsPolicy returns if the metrics endpoint is enabled,
+// and the bearer token needed to read it, if any.
+func (rns *Rinse) MetricsPolicy() (enabled bool, token string) {
+	rns.mu.Lock()
+	enabled = rns.metricsEnabled
+	token = rns.metricsToken
+	rns.mu.Unlock()
+	return
+}
+
+// ServeMetrics serves the Prometheus metrics if they are enabled
+// and the request has the bearer token required by the settings.
+func (rns *Rinse) ServeMetrics(hw http.ResponseWriter, hr *http.Request) {
+	enabled, token := rns.MetricsPolicy()
+	if !enabled {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+		return
+	}
+	if token != "" {
+		given, ok := strings.CutPrefix(hr.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			hw.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			SendHTTPError(hw, http.StatusUnauthorized, nil)
+			return
+		}
+	}
+	rns.metricsHandler.ServeHTTP(hw, hr)
+}
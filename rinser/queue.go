@@ -0,0 +1,191 @@
+package rinser
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrJobNotQueued = errors.New("job is not queued")
+
+func (job *Job) GetPriority() (n int) {
+	job.mu.Lock()
+	n = job.Priority
+	job.mu.Unlock()
+	return
+}
+
+// getQueueSeq returns the position an admin moved the queued job to
+// among those of the same priority, counting from one, or zero if not moved.
+func (job *Job) getQueueSeq() (n int) {
+	job.mu.Lock()
+	n = job.queueSeq
+	job.mu.Unlock()
+	return
+}
+
+// SetPriority sets the job priority. Queued jobs with
+// higher priority are started before those with lower.
+// Changing it forgets where an admin moved the job in the queue.
+func (job *Job) SetPriority(n int) {
+	job.mu.Lock()
+	changed := job.Priority != n
+	job.Priority = n
+	if changed {
+		job.queueSeq = 0
+	}
+	job.mu.Unlock()
+	if changed {
+		job.save()
+		job.Rinse.Jaws.Dirty(job, uiQueue{job.Rinse})
+	}
+}
+
+// schedulesBeforeLocked returns true if job a should be started before job b.
+//
+// Higher priority jobs go first. For equal priority, jobs an admin moved
+// go first in the order they were moved to. Then users take turns,
+// preferring the user with the fewest running jobs and then the user
+// who least recently had a job started. A user's own jobs keep queue order.
+func (rns *Rinse) schedulesBeforeLocked(a, b *Job, running map[string]int) bool {
+	if pa, pb := a.GetPriority(), b.GetPriority(); pa != pb {
+		return pa > pb
+	}
+	if sa, sb := a.getQueueSeq(), b.getQueueSeq(); sa != sb {
+		return sb == 0 || (sa != 0 && sa < sb)
+	}
+	if a.Email == b.Email {
+		return false
+	}
+	if ra, rb := running[a.Email], running[b.Email]; ra != rb {
+		return ra < rb
+	}
+	return rns.lastStart[a.Email].Before(rns.lastStart[b.Email])
+}
+
+// nextJobLocked returns the queued job to start next, or nil if
+// there are none or MaxConcurrent jobs are already running.
+// Jobs whose user is at their MaxRunning quota are passed over.
+func (rns *Rinse) nextJobLocked() (nextJob *Job) {
+	total := 0
+	running := map[string]int{}
+	users := map[string]struct{}{}
+	var queued []*Job
+	for _, job := range rns.jobs {
+		users[job.Email] = struct{}{}
+		switch job.State() {
+		case JobNew:
+			queued = append(queued, job)
+		case JobFailed, JobFinished:
+		default:
+			total++
+			running[job.Email]++
+		}
+	}
+	for email := range rns.lastStart {
+		if _, ok := users[email]; !ok {
+			delete(rns.lastStart, email)
+		}
+	}
+	if total < rns.maxConcurrent {
+		for _, job := range queued {
+			if q := rns.quotaForLocked(job.Email); q.MaxRunning > 0 && running[job.Email] >= q.MaxRunning {
+				continue
+			}
+			if nextJob == nil || rns.schedulesBeforeLocked(job, nextJob, running) {
+				nextJob = job
+			}
+		}
+	}
+	return
+}
+
+func (rns *Rinse) startNextJobLocked() (err error) {
+	if job := rns.nextJobLocked(); job != nil {
+		if err = job.Start(); err == nil {
+			rns.lastStart[job.Email] = time.Now()
+			rns.Jaws.Dirty(uiQueue{rns})
+		} else {
+			rns.Error("startjob", "job", job.Name, "err", err)
+		}
+	}
+	return
+}
+
+// QueuedJobs returns the jobs waiting to start, highest priority first,
+// then those an admin moved, and otherwise in queue order.
+func (rns *Rinse) QueuedJobs() (jobs []*Job) {
+	rns.mu.Lock()
+	defer rns.mu.Unlock()
+	return rns.queuedJobsLocked()
+}
+
+func (rns *Rinse) queuedJobsLocked() (jobs []*Job) {
+	for _, job := range rns.jobs {
+		if job.State() == JobNew {
+			jobs = append(jobs, job)
+		}
+	}
+	slices.SortStableFunc(jobs, func(a, b *Job) int {
+		if pa, pb := a.GetPriority(), b.GetPriority(); pa != pb {
+			return pb - pa
+		}
+		sa, sb := a.getQueueSeq(), b.getQueueSeq()
+		switch {
+		case sa == sb:
+			return 0
+		case sa == 0:
+			return 1
+		case sb == 0:
+			return -1
+		}
+		return sa - sb
+	})
+	return
+}
+
+// MoveJob moves a queued job to the given position among the queued jobs
+// of the same priority, where zero is the front of the queue. The queued jobs
+// of that priority are then started in the order shown, ahead of jobs added later.
+func (rns *Rinse) MoveJob(job *Job, position int) (err error) {
+	rns.mu.Lock()
+	defer rns.mu.Unlock()
+	err = ErrJobNotQueued
+	if job.State() == JobNew {
+		err = nil
+		var peers []*Job
+		for _, x := range rns.queuedJobsLocked() {
+			if x != job && x.GetPriority() == job.GetPriority() {
+				peers = append(peers, x)
+			}
+		}
+		peers = slices.Insert(peers, min(max(0, position), len(peers)), job)
+		for i, x := range peers {
+			x.mu.Lock()
+			changed := x.queueSeq != i+1
+			x.queueSeq = i + 1
+			x.mu.Unlock()
+			if changed {
+				x.save()
+			}
+		}
+		rns.Jaws.Dirty(uiQueue{rns})
+	}
+	return
+}
+
+// QueuePosition returns the position of a queued job among the
+// queued jobs of the same priority, or -1 if it is not queued.
+func (rns *Rinse) QueuePosition(job *Job) (position int) {
+	position = -1
+	n := 0
+	for _, x := range rns.QueuedJobs() {
+		if x == job {
+			return n
+		}
+		if x.GetPriority() == job.GetPriority() {
+			n++
+		}
+	}
+	return
+}
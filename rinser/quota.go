@@ -0,0 +1,157 @@
+package rinser
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/linkdata/bytecount"
+)
+
+var ErrQuotaCeiling = errors.New("quota ceiling exceeded")
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Quota limits the jobs of a user. Zero values mean no limit.
+type Quota struct {
+	MaxQueued  int `json:",omitempty"` // jobs waiting to start
+	MaxRunning int `json:",omitempty"` // jobs running at the same time
+	MaxDiskMB  int `json:",omitempty"` // total disk used by the users jobs
+	MaxSizeMB  int `json:",omitempty"` // ceiling for a jobs maxsizemb
+	MaxTimeSec int `json:",omitempty"` // ceiling for a jobs maxtimesec
+}
+
+func groupHasMember(members []string, email string) bool {
+	for _, member := range members {
+		if strings.HasPrefix(member, "@") {
+			if strings.HasSuffix(email, member) {
+				return true
+			}
+		} else if member == email {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaForLocked returns the quota for the user's email if there is one,
+// otherwise that of the first group (by name) the user is a member of
+// which has a quota, otherwise the quota for "*".
+func (rns *Rinse) quotaForLocked(email string) Quota {
+	if q, ok := rns.quotas[email]; ok {
+		return q
+	}
+	for _, name := range slices.Sorted(maps.Keys(rns.groups)) {
+		if groupHasMember(rns.groups[name], email) {
+			if q, ok := rns.quotas[name]; ok {
+				return q
+			}
+		}
+	}
+	return rns.quotas["*"]
+}
+
+func (rns *Rinse) QuotaFor(email string) (q Quota) {
+	rns.mu.Lock()
+	q = rns.quotaForLocked(email)
+	rns.mu.Unlock()
+	return
+}
+
+func exceedsCeiling(v, ceiling int) bool {
+	return ceiling > 0 && (v < 1 || v > ceiling)
+}
+
+// clampToCeiling returns the ceiling if v exceeds it, otherwise v.
+func clampToCeiling(v, ceiling int) int {
+	if exceedsCeiling(v, ceiling) {
+		return ceiling
+	}
+	return v
+}
+
+// defaultLimitsLocked returns the admin default maxsizemb and maxtimesec,
+// clamped to the quota ceilings of the user. Jobs that don't ask for limits
+// of their own get these, so they are never rejected by a ceiling.
+func (rns *Rinse) defaultLimitsLocked(email string) (maxSizeMB, maxTimeSec int) {
+	q := rns.quotaForLocked(email)
+	return clampToCeiling(rns.maxSizeMB, q.MaxSizeMB), clampToCeiling(rns.maxTimeSec, q.MaxTimeSec)
+}
+
+// checkQuotaLocked returns an error if the user may not add a job with the given limits
+// that uses extraDiskuse bytes of disk.
+func (rns *Rinse) checkQuotaLocked(email string, maxSizeMB, maxTimeSec int, extraDiskuse int64) (err error) {
+	q := rns.quotaForLocked(email)
+	if exceedsCeiling(maxSizeMB, q.MaxSizeMB) {
+		return fmt.Errorf("%w: maxsizemb must be 1 to %d", ErrQuotaCeiling, q.MaxSizeMB)
+	}
+	if exceedsCeiling(maxTimeSec, q.MaxTimeSec) {
+		return fmt.Errorf("%w: maxtimesec must be 1 to %d", ErrQuotaCeiling, q.MaxTimeSec)
+	}
+	queued, diskuse := rns.usageLocked(email)
+	if q.MaxQueued > 0 && queued >= q.MaxQueued {
+		return fmt.Errorf("%w: %d jobs already queued", ErrQuotaExceeded, queued)
+	}
+	return checkDiskQuota(q, diskuse+extraDiskuse)
+}
+
+// checkChildQuotaLocked returns an error if the user may not add a job unpacked
+// from an archive or message that uses extraDiskuse bytes of disk. Such jobs
+// don't count as queued, but their disk use counts like that of any other job.
+func (rns *Rinse) checkChildQuotaLocked(email string, extraDiskuse int64) (err error) {
+	_, diskuse := rns.usageLocked(email)
+	return checkDiskQuota(rns.quotaForLocked(email), diskuse+extraDiskuse)
+}
+
+// usageLocked returns the number of queued jobs of the user and the disk they use.
+func (rns *Rinse) usageLocked(email string) (queued int, diskuse int64) {
+	for _, job := range rns.jobs {
+		if job.Email == email {
+			if job.State() == JobNew {
+				queued++
+			}
+			job.mu.Lock()
+			diskuse += job.Diskuse
+			job.mu.Unlock()
+		}
+	}
+	return
+}
+
+func checkDiskQuota(q Quota, diskuse int64) (err error) {
+	if maxDisk := int64(q.MaxDiskMB) * 1024 * 1024; maxDisk > 0 && diskuse >= maxDisk {
+		err = fmt.Errorf("%w: jobs use %v of %dMB disk", ErrQuotaExceeded, bytecount.N(diskuse), q.MaxDiskMB)
+	}
+	return
+}
+
+// checkRetryQuotaLocked returns an error if the user may not queue the failed job again.
+// The disk the job uses is already counted.
+func (rns *Rinse) checkRetryQuotaLocked(job *Job) (err error) {
+	if job.Parent == uuid.Nil {
+		return rns.checkQuotaLocked(job.Email, job.MaxSizeMB, job.MaxTimeSec, 0)
+	}
+	return rns.checkChildQuotaLocked(job.Email, 0)
+}
+
+// CheckQuota returns an error if the user may not add a job with the given limits.
+func (rns *Rinse) CheckQuota(email string, maxSizeMB, maxTimeSec int) (err error) {
+	rns.mu.Lock()
+	defer rns.mu.Unlock()
+	return rns.checkQuotaLocked(email, maxSizeMB, maxTimeSec, 0)
+}
+
+// quotaHTTPStatus returns the HTTP status code for a quota error,
+// or code if err is not a quota error.
+func quotaHTTPStatus(err error, code int) int {
+	switch {
+	case errors.Is(err, ErrQuotaCeiling):
+		return http.StatusForbidden
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	}
+	return code
+}
@@ -0,0 +1,23 @@
+package rinser
+
+import "net/http"
+
+// RESTGETBatchesUUID godoc
+//
+//	@Summary		Get batch state.
+//	@Description	Get the aggregated state of the jobs in a batch, including the jobs unpacked from them.
+//	@Tags			batches
+//	@Accept			json
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{object}	Batch
+//	@Failure		404				{object}	HTTPError
+//	@Router			/batches/{uuid} [get]
+func (rns *Rinse) RESTGETBatchesUUID(hw http.ResponseWriter, hr *http.Request) {
+	if batch := rns.FindBatch(hr.PathValue("uuid")); batch != nil {
+		HTTPJSON(hw, http.StatusOK, batch)
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
@@ -0,0 +1,51 @@
+package rinser
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RESTGETBatchesUUIDZip godoc
+//
+//	@Summary		Get the rinsed documents of a batch.
+//	@Description	Get a ZIP file with the rinsed documents of all finished jobs in the batch,
+//	@Description	once none of its jobs are waiting or running.
+//	@Tags			batches
+//	@Accept			*/*
+//	@Produce		application/zip
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{file}		file	""
+//	@Success		202				{object}	Batch	"Documents not yet ready."
+//	@Failure		404				{object}	HTTPError
+//	@Failure		410				{object}	HTTPError	"All jobs failed."
+//	@Router			/batches/{uuid}/zip [get]
+func (rns *Rinse) RESTGETBatchesUUIDZip(hw http.ResponseWriter, hr *http.Request) {
+	if batch := rns.FindBatch(hr.PathValue("uuid")); batch != nil {
+		switch {
+		case !batch.Done:
+			HTTPJSON(hw, http.StatusAccepted, batch)
+		case batch.Finished == 0:
+			SendHTTPError(hw, http.StatusGone, ErrBatchFailed)
+		default:
+			hdr := hw.Header()
+			hdr["Content-Type"] = []string{"application/zip"}
+			hdr["Content-Disposition"] = []string{fmt.Sprintf(`attachment; filename="batch-%s.zip"`, batch.UUID)}
+			jobs := rns.downloadable(hr, batch.Jobs)
+			if err := writeBatch(hw, jobs); err == nil {
+				for _, job := range jobs {
+					if job.Parent == uuid.Nil {
+						job.downloaded(true)
+					}
+				}
+			} else {
+				rns.Error("RESTGETBatchesUUIDZip", "batch", batch.UUID, "err", err)
+			}
+		}
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
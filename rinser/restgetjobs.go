@@ -1,19 +1,26 @@
 package rinser
 
-import "net/http"
+import (
+	"net/http"
+	"slices"
+)
 
 // RESTGETJobs godoc
 //
 //	@Summary		List jobs
-//	@Description	Get a list of all jobs.
+//	@Description	Get a list of all jobs. Jobs unpacked from an archive or email follow their parent job.
 //	@Tags			jobs
 //	@Accept			*/*
 //	@Produce		json
+//	@Param			parent			query	string	false	"only list jobs unpacked from this job"
 //	@Param			Authorization	header	string	false	"JWT token"
 //	@Success		200				{array}	Job
 //	@Router			/jobs [get]
 func (rns *Rinse) RESTGETJobs(hw http.ResponseWriter, hr *http.Request) {
-	list := rns.JobList(rns.GetEmail(hr))
+	list := groupJobs(rns.JobList(rns.GetEmail(hr)))
+	if s := hr.URL.Query().Get("parent"); s != "" {
+		list = slices.DeleteFunc(list, func(job *Job) bool { return job.Parent.String() != s })
+	}
 	if list == nil {
 		list = []*Job{}
 	}
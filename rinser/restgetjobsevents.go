@@ -0,0 +1,96 @@
+package rinser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrStreamingUnsupported = errors.New("streaming unsupported")
+
+const eventKeepAlive = 15 * time.Second
+
+// RESTGETJobsEvents godoc
+//
+//	@Summary		Stream job events.
+//	@Description	Stream Server-Sent Events as the jobs the user may see change state, have pages rendered or OCR-ed, finish or fail.
+//	@Description	Each event has the JobEvent JSON as data, and the event field as event name.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		text/event-stream
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{object}	JobEvent
+//	@Failure		500				{object}	HTTPError
+//	@Router			/jobs/events [get]
+func (rns *Rinse) RESTGETJobsEvents(hw http.ResponseWriter, hr *http.Request) {
+	sub := rns.subscribeEvents(rns.GetEmail(hr), uuid.Nil)
+	defer rns.unsubscribeEvents(sub)
+	rns.serveEvents(hw, hr, sub, nil)
+}
+
+// serveEvents streams the subscriber's events until the client goes away,
+// or if job is not nil, until the job is done.
+func (rns *Rinse) serveEvents(hw http.ResponseWriter, hr *http.Request, sub *eventSub, job *Job) {
+	flusher, ok := hw.(http.Flusher)
+	if !ok {
+		SendHTTPError(hw, http.StatusInternalServerError, ErrStreamingUnsupported)
+		return
+	}
+	hdr := hw.Header()
+	hdr["Content-Type"] = []string{"text/event-stream"}
+	hdr["Cache-Control"] = []string{"no-cache"}
+	hdr["X-Accel-Buffering"] = []string{"no"}
+	hw.WriteHeader(http.StatusOK)
+
+	var done <-chan struct{}
+	if job != nil {
+		done = job.StoppedCh()
+		if err := writeEvent(hw, job.Event(JobEventState)); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-hr.Context().Done():
+			return
+		case ev := <-sub.ch:
+			err = writeEvent(hw, ev)
+			if done != nil && (ev.Event == JobEventFinished || ev.Event == JobEventFailed) {
+				flusher.Flush()
+				return
+			}
+		case <-done:
+			// the job stopped before we subscribed, or its last event was dropped
+			event := JobEventFinished
+			if job.State() == JobFailed {
+				event = JobEventFailed
+			}
+			_ = writeEvent(hw, job.Event(event))
+			flusher.Flush()
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(hw, ": keep-alive\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(hw http.ResponseWriter, ev JobEvent) (err error) {
+	var b []byte
+	if b, err = json.Marshal(ev); err == nil {
+		_, err = fmt.Fprintf(hw, "event: %s\ndata: %s\n\n", ev.Event, b)
+	}
+	return
+}
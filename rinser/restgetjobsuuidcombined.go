@@ -0,0 +1,145 @@
+package rinser
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// RESTGETJobsUUIDCombined godoc
+//
+//	@Summary		Get the rinsed documents of an archive or email.
+//	@Description	Get a ZIP file with the rinsed documents of the job and all jobs unpacked from it.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		application/zip
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{file}		file	""
+//	@Success		202				{object}	Job		"Documents not yet ready."
+//	@Failure		403				{object}	HTTPError	"Job is quarantined."
+//	@Failure		404				{object}	HTTPError
+//	@Failure		410				{object}	HTTPError	"Job failed."
+//	@Router			/jobs/{uuid}/combined [get]
+func (rns *Rinse) RESTGETJobsUUIDCombined(hw http.ResponseWriter, hr *http.Request) {
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil {
+		if rns.quarantined(hr, job) {
+			SendHTTPError(hw, http.StatusForbidden, ErrQuarantined)
+			return
+		}
+		switch job.State() {
+		case JobFailed:
+			SendHTTPError(hw, http.StatusGone, job.Error)
+			return
+		case JobFinished:
+			rns.mu.Lock()
+			children := rns.childrenLocked(job)
+			ready := rns.childrenDoneLocked(job)
+			rns.mu.Unlock()
+			if len(children) > 0 {
+				if ready {
+					hdr := hw.Header()
+					hdr["Content-Type"] = []string{"application/zip"}
+					hdr["Content-Disposition"] = []string{fmt.Sprintf(`attachment; filename="%s"`, job.CombinedName())}
+					if err := writeCombined(hw, job, rns.downloadable(hr, children)); err == nil {
+						job.downloaded(true)
+					} else {
+						rns.Error("RESTGETJobsUUIDCombined", "job", job.Name, "err", err)
+					}
+					return
+				}
+				HTTPJSON(hw, http.StatusAccepted, job)
+				return
+			}
+			SendHTTPError(hw, http.StatusNotFound, nil)
+		default:
+			HTTPJSON(hw, http.StatusAccepted, job)
+		}
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
+
+// CombinedError names a document left out of a combined ZIP file because rinsing it failed.
+type CombinedError struct {
+	Name  string `json:"name" example:"docs/example.docx"`
+	UUID  string `json:"uuid" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Error string `json:"error" example:"no progress made for 1m0s"`
+}
+
+// combinedErrorsName is the file in a combined ZIP file that lists the failed documents.
+const combinedErrorsName = "errors.json"
+
+// writeCombined writes a ZIP file with the rinsed document of the parent
+// job, if it has one, and of each finished child job, named after the
+// document's path within the archive. Children that failed are listed
+// in "errors.json".
+func writeCombined(w io.Writer, parent *Job, children []*Job) (err error) {
+	zw := zip.NewWriter(w)
+	var names []string
+	var failed []CombinedError
+	jobs := children
+	if !parent.IsArchive() {
+		jobs = append([]*Job{parent}, children...)
+	}
+	for _, job := range jobs {
+		switch job.State() {
+		case JobFinished:
+			base := job.ResultName()
+			if job != parent {
+				base = path.Join(path.Dir(job.Name), base)
+			}
+			if names, err = addZipResult(zw, names, base, job); err != nil {
+				return
+			}
+		case JobFailed:
+			ce := CombinedError{Name: job.Name, UUID: job.UUID.String(), Error: "failed"}
+			job.mu.Lock()
+			if job.Error != nil {
+				ce.Error = job.Error.Error()
+			}
+			job.mu.Unlock()
+			failed = append(failed, ce)
+		}
+	}
+	if len(failed) > 0 {
+		var fw io.Writer
+		if fw, err = zw.Create(combinedErrorsName); err == nil {
+			enc := json.NewEncoder(fw)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(failed)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return zw.Close()
+}
+
+// addZipResult adds the rinsed document of the job to the ZIP file as base,
+// or with a number appended if names already has base, and returns
+// names with the name used added.
+func addZipResult(zw *zip.Writer, names []string, base string, job *Job) ([]string, error) {
+	name := base
+	for i := 2; slices.Contains(names, name); i++ {
+		name = fmt.Sprintf("%s-%d.pdf", strings.TrimSuffix(base, ".pdf"), i)
+	}
+	f, err := os.Open(job.ResultPath())
+	if err == nil {
+		var fw io.Writer
+		if fw, err = zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store}); err == nil {
+			var n int64
+			n, err = io.Copy(fw, f)
+			metricBytesOut.Add(float64(n))
+		}
+		_ = f.Close()
+	}
+	return append(names, name), err
+}
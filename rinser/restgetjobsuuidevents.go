@@ -0,0 +1,29 @@
+package rinser
+
+import "net/http"
+
+// RESTGETJobsUUIDEvents godoc
+//
+//	@Summary		Stream events for a job.
+//	@Description	Stream Server-Sent Events as the job changes state, has pages rendered or OCR-ed, finishes or fails.
+//	@Description	The first event has the current state of the job, and the stream ends once the job is done.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		text/event-stream
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{object}	JobEvent
+//	@Failure		404				{object}	HTTPError
+//	@Failure		500				{object}	HTTPError
+//	@Router			/jobs/{uuid}/events [get]
+func (rns *Rinse) RESTGETJobsUUIDEvents(hw http.ResponseWriter, hr *http.Request) {
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil {
+		sub := rns.subscribeEvents(rns.GetEmail(hr), job.UUID)
+		defer rns.unsubscribeEvents(sub)
+		if sub.canSee(job) {
+			rns.serveEvents(hw, hr, sub, job)
+			return
+		}
+	}
+	SendHTTPError(hw, http.StatusNotFound, nil)
+}
@@ -0,0 +1,37 @@
+package rinser
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// RESTGETJobsUUIDManifest godoc
+//
+//	@Summary		Get the jobs provenance manifest.
+//	@Description	Get the SHA-256 of the jobs document and outputs, with the stage history, tool versions and settings used.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{object}	Manifest
+//	@Success		202				{object}	Job			"Job not yet finished."
+//	@Failure		404				{object}	HTTPError
+//	@Failure		410				{object}	HTTPError	"Job failed."
+//	@Router			/jobs/{uuid}/manifest [get]
+func (rns *Rinse) RESTGETJobsUUIDManifest(hw http.ResponseWriter, hr *http.Request) {
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil {
+		if job.State() == JobFailed {
+			SendHTTPError(hw, http.StatusGone, job.Error)
+			return
+		}
+		if job.HasManifest() {
+			hw.Header()["Content-Disposition"] = []string{fmt.Sprintf(`attachment; filename="%s"`, job.ManifestName())}
+			HTTPJSON(hw, http.StatusOK, job.Manifest())
+		} else {
+			HTTPJSON(hw, http.StatusAccepted, job)
+		}
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
@@ -18,12 +18,17 @@ import (
 //	@Param			Authorization	header		string	false	"JWT token"
 //	@Success		200				{file}		file	""
 //	@Success		202				{object}	Job		"Metadata not yet ready."
+//	@Failure		403				{object}	HTTPError	"Job is quarantined."
 //	@Failure		404				{object}	HTTPError
 //	@Failure		410				{object}	HTTPError	"Job failed."
 //	@Failure		500				{object}	HTTPError
 //	@Router			/jobs/{uuid}/meta [get]
 func (rns *Rinse) RESTGETJobsUUIDMeta(hw http.ResponseWriter, hr *http.Request) {
 	if job := rns.FindJob(hr.PathValue("uuid")); job != nil {
+		if rns.quarantined(hr, job) {
+			SendHTTPError(hw, http.StatusForbidden, ErrQuarantined)
+			return
+		}
 		if job.State() == JobFailed {
 			SendHTTPError(hw, http.StatusGone, job.Error)
 			return
@@ -0,0 +1,72 @@
+package rinser
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// RESTGETJobsUUIDOutputFormat godoc
+//
+//	@Summary		Get the jobs output in a given format.
+//	@Description	Get the jobs output in one of the formats requested when the job was added.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		application/pdf
+//	@Produce		text/plain
+//	@Produce		text/html
+//	@Produce		application/xml
+//	@Produce		text/tab-separated-values
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			format			path		string	true	"txt"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{file}		file	""
+//	@Success		202				{object}	Job		"Output not yet ready."
+//	@Failure		403				{object}	HTTPError	"Job is quarantined."
+//	@Failure		404				{object}	HTTPError
+//	@Failure		410				{object}	HTTPError	"Job failed."
+//	@Failure		500				{object}	HTTPError
+//	@Router			/jobs/{uuid}/output/{format} [get]
+func (rns *Rinse) RESTGETJobsUUIDOutputFormat(hw http.ResponseWriter, hr *http.Request) {
+	format := hr.PathValue("format")
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil && job.HasFormat(format) && !job.IsArchive() {
+		if rns.quarantined(hr, job) {
+			SendHTTPError(hw, http.StatusForbidden, ErrQuarantined)
+			return
+		}
+		switch job.State() {
+		case JobFailed:
+			SendHTTPError(hw, http.StatusGone, job.Error)
+			return
+		case JobFinished:
+			fpath := job.OutputPath(format)
+			fi, err := os.Stat(fpath)
+			if err == nil {
+				hdr := hw.Header()
+				hdr["Content-Length"] = []string{strconv.FormatInt(fi.Size(), 10)}
+				hdr["Content-Type"] = []string{outputFormats[format].mime}
+				hdr["Content-Disposition"] = []string{fmt.Sprintf(`attachment; filename="%s"`, job.OutputName(format))}
+				var f *os.File
+				if f, err = os.Open(fpath); err == nil /* #nosec G304 */ {
+					defer f.Close()
+					var n int64
+					n, err = io.Copy(hw, f)
+					metricBytesOut.Add(float64(n))
+					if err == nil {
+						job.downloaded(outputFormats[format].mime == "application/pdf")
+						return
+					}
+				}
+			}
+			rns.Error("RESTGETJobsUUIDOutputFormat", "job", job.Name, "format", format, "err", err)
+			SendHTTPError(hw, http.StatusInternalServerError, err)
+		default:
+			HTTPJSON(hw, http.StatusAccepted, job)
+		}
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
@@ -27,6 +27,7 @@ import (
 //	@Success		200				{jpeg}		jpeg	""
 //	@Success		202				{object}	Job		"Preview not yet ready."
 //	@Failure		400				{object}	HTTPError
+//	@Failure		403				{object}	HTTPError	"Job is quarantined."
 //	@Failure		404				{object}	HTTPError
 //	@Failure		410				{object}	HTTPError	"Job failed."
 //	@Failure		500				{object}	HTTPError
@@ -35,6 +36,10 @@ func (rns *Rinse) RESTGETJobsUUIDPreview(w http.ResponseWriter, r *http.Request)
 	const iframeStart = `<!DOCTYPE html><html><body><img alt="%s" src="data:image/jpeg;base64,`
 	const iframeEnd = `" width="%dpx"></body></html>`
 	if job := rns.FindJob(r.PathValue("uuid")); job != nil {
+		if rns.quarantined(r, job) {
+			SendHTTPError(w, http.StatusForbidden, ErrQuarantined)
+			return
+		}
 		switch job.State() {
 		case JobNew:
 			HTTPJSON(w, http.StatusAccepted, job)
@@ -11,7 +11,7 @@ import (
 // RESTGETJobsUUIDRinsed godoc
 //
 //	@Summary		Get the jobs rinsed document.
-//	@Description	Get the jobs rinsed document.
+//	@Description	Get the jobs rinsed document. For archive jobs, this is the same as /jobs/{uuid}/combined.
 //	@Tags			jobs
 //	@Accept			*/*
 //	@Produce		application/pdf
@@ -20,17 +20,26 @@ import (
 //	@Param			Authorization	header		string	false	"JWT token"
 //	@Success		200				{file}		file	""
 //	@Success		202				{object}	Job		"Rinsed version not yet ready."
+//	@Failure		403				{object}	HTTPError	"Job is quarantined."
 //	@Failure		404				{object}	HTTPError
 //	@Failure		410				{object}	HTTPError	"Job failed."
 //	@Failure		500				{object}	HTTPError
 //	@Router			/jobs/{uuid}/rinsed [get]
 func (rns *Rinse) RESTGETJobsUUIDRinsed(hw http.ResponseWriter, hr *http.Request) {
 	if job := rns.FindJob(hr.PathValue("uuid")); job != nil {
+		if rns.quarantined(hr, job) {
+			SendHTTPError(hw, http.StatusForbidden, ErrQuarantined)
+			return
+		}
 		switch job.State() {
 		case JobFailed:
 			SendHTTPError(hw, http.StatusGone, job.Error)
 			return
 		case JobFinished:
+			if job.IsArchive() {
+				rns.RESTGETJobsUUIDCombined(hw, hr)
+				return
+			}
 			fi, err := os.Stat(job.ResultPath())
 			if err == nil {
 				hdr := hw.Header()
@@ -40,8 +49,11 @@ func (rns *Rinse) RESTGETJobsUUIDRinsed(hw http.ResponseWriter, hr *http.Request
 				var f *os.File
 				if f, err = os.Open(job.ResultPath()); err == nil {
 					defer f.Close()
-					if _, err = io.Copy(hw, f); err == nil {
-						job.downloaded()
+					var n int64
+					n, err = io.Copy(hw, f)
+					metricBytesOut.Add(float64(n))
+					if err == nil {
+						job.downloaded(true)
 						return
 					}
 				}
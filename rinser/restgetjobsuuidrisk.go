@@ -0,0 +1,43 @@
+package rinser
+
+import (
+	"net/http"
+)
+
+// RESTGETJobsUUIDRisk godoc
+//
+//	@Summary		Get the jobs document risk report.
+//	@Description	Get the risky content found in the jobs document metadata, such as macros, JavaScript and embedded objects.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{object}	Risk
+//	@Success		202				{object}	Job	"Risk report not yet ready."
+//	@Failure		403				{object}	HTTPError	"Job is quarantined."
+//	@Failure		404				{object}	HTTPError
+//	@Failure		410				{object}	HTTPError	"Job failed."
+//	@Router			/jobs/{uuid}/risk [get]
+func (rns *Rinse) RESTGETJobsUUIDRisk(hw http.ResponseWriter, hr *http.Request) {
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil {
+		if rns.quarantined(hr, job) {
+			SendHTTPError(hw, http.StatusForbidden, ErrQuarantined)
+			return
+		}
+		if risk := job.GetRisk(); risk != nil {
+			HTTPJSON(hw, http.StatusOK, risk)
+			return
+		}
+		switch job.State() {
+		case JobFailed:
+			SendHTTPError(hw, http.StatusGone, job.Error)
+		case JobFinished:
+			SendHTTPError(hw, http.StatusNotFound, nil)
+		default:
+			HTTPJSON(hw, http.StatusAccepted, job)
+		}
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
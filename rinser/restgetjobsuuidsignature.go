@@ -0,0 +1,66 @@
+package rinser
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// RESTGETJobsUUIDSignature godoc
+//
+//	@Summary		Get the detached signature of the jobs rinsed document.
+//	@Description	Get a detached CMS signature of the jobs rinsed PDF, made with the key of this instance.
+//	@Description	Only available if signing is enabled.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		application/pkcs7-signature
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{file}		file	""
+//	@Success		202				{object}	Job		"Signature not yet ready."
+//	@Failure		403				{object}	HTTPError	"Job is quarantined."
+//	@Failure		404				{object}	HTTPError
+//	@Failure		410				{object}	HTTPError	"Job failed."
+//	@Failure		500				{object}	HTTPError
+//	@Router			/jobs/{uuid}/signature [get]
+func (rns *Rinse) RESTGETJobsUUIDSignature(hw http.ResponseWriter, hr *http.Request) {
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil && rns.signer != nil && !job.IsArchive() {
+		if rns.quarantined(hr, job) {
+			SendHTTPError(hw, http.StatusForbidden, ErrQuarantined)
+			return
+		}
+		switch job.State() {
+		case JobFailed:
+			SendHTTPError(hw, http.StatusGone, job.Error)
+			return
+		case JobFinished:
+			fi, err := os.Stat(job.SignaturePath())
+			if err == nil {
+				hdr := hw.Header()
+				hdr["Content-Length"] = []string{strconv.FormatInt(fi.Size(), 10)}
+				hdr["Content-Type"] = []string{"application/pkcs7-signature"}
+				hdr["Content-Disposition"] = []string{fmt.Sprintf(`attachment; filename="%s"`, job.SignatureName())}
+				var f *os.File
+				if f, err = os.Open(job.SignaturePath()); err == nil /* #nosec G304 */ {
+					defer f.Close()
+					if _, err = io.Copy(hw, f); err == nil {
+						return
+					}
+				}
+			}
+			if os.IsNotExist(err) {
+				SendHTTPError(hw, http.StatusNotFound, nil)
+				return
+			}
+			rns.Error("RESTGETJobsUUIDSignature", "job", job.Name, "err", err)
+			SendHTTPError(hw, http.StatusInternalServerError, err)
+		default:
+			HTTPJSON(hw, http.StatusAccepted, job)
+		}
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
@@ -0,0 +1,90 @@
+package rinser
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	contentnegotiation "gitlab.com/jamietanna/content-negotiation-go"
+)
+
+// RESTGETJobsUUIDText godoc
+//
+//	@Summary		Get the jobs OCR text.
+//	@Description	Get the text of the jobs rinsed document, with a form feed ending each page.
+//	@Description	With "Accept: application/json" the text is given per page along with its mean word confidence.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		text/plain
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{file}		file	""
+//	@Success		200				{object}	Text
+//	@Success		202				{object}	Job	"Text not yet ready."
+//	@Failure		400				{object}	HTTPError
+//	@Failure		403				{object}	HTTPError	"Job is quarantined."
+//	@Failure		404				{object}	HTTPError
+//	@Failure		410				{object}	HTTPError	"Job failed."
+//	@Failure		500				{object}	HTTPError
+//	@Router			/jobs/{uuid}/text [get]
+func (rns *Rinse) RESTGETJobsUUIDText(hw http.ResponseWriter, hr *http.Request) {
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil && !job.IsArchive() {
+		if rns.quarantined(hr, job) {
+			SendHTTPError(hw, http.StatusForbidden, ErrQuarantined)
+			return
+		}
+		switch job.State() {
+		case JobFailed:
+			SendHTTPError(hw, http.StatusGone, job.Error)
+			return
+		case JobFinished:
+			negotiator := contentnegotiation.NewNegotiator("text/plain", "application/json")
+			negotiated, _, err := negotiator.Negotiate(hr.Header.Get("Accept"))
+			if err != nil {
+				SendHTTPError(hw, http.StatusBadRequest, err)
+				return
+			}
+			if negotiated.String() == "application/json" {
+				var text Text
+				if text, err = job.Text(); err == nil {
+					HTTPJSON(hw, http.StatusOK, text)
+					job.downloaded(false)
+					return
+				}
+			} else {
+				fpath := job.OutputPath("txt")
+				var fi os.FileInfo
+				if fi, err = os.Stat(fpath); err == nil {
+					hdr := hw.Header()
+					hdr["Content-Length"] = []string{strconv.FormatInt(fi.Size(), 10)}
+					hdr["Content-Type"] = []string{outputFormats["txt"].mime}
+					hdr["Content-Disposition"] = []string{fmt.Sprintf(`inline; filename="%s"`, job.OutputName("txt"))}
+					var f *os.File
+					if f, err = os.Open(fpath); err == nil /* #nosec G304 */ {
+						defer f.Close()
+						var n int64
+						n, err = io.Copy(hw, f)
+						metricBytesOut.Add(float64(n))
+						if err == nil {
+							job.downloaded(false)
+							return
+						}
+					}
+				}
+			}
+			if os.IsNotExist(err) {
+				SendHTTPError(hw, http.StatusNotFound, nil)
+				return
+			}
+			rns.Error("RESTGETJobsUUIDText", "job", job.Name, "err", err)
+			SendHTTPError(hw, http.StatusInternalServerError, err)
+		default:
+			HTTPJSON(hw, http.StatusAccepted, job)
+		}
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
@@ -0,0 +1,206 @@
+package rinser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// RESTPOSTBatches godoc
+//
+//	@Summary		Add a batch of jobs
+//	@Description	Add one job for each file using multipart/form-data, or for each URL in a JSON array.
+//	@Description	The query parameters are the defaults for all jobs in the batch.
+//	@Description	If any of the jobs can not be added, none are.
+//	@Tags			batches
+//	@Accept			json
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			addjoburls		body		[]AddJobURL	false	"Add jobs by URL"
+//	@Param			file			formData	file		false	"one or more files"
+//	@Param			lang			query		string		false	"eng"
+//	@Param			maxsizemb		query		int			false	"2048"
+//	@Param			maxtimesec		query		int			false	"86400"
+//	@Param			cleanupsec		query		int			false	"600"
+//	@Param			timeoutsec		query		int			false	"600"
+//	@Param			cleanupgotten	query		bool		false	"true"
+//	@Param			private			query		bool		false	"false"
+//	@Param			formats			query		string		false	"pdfa,txt,hocr,alto,tsv"
+//	@Param			dpi				query		int			false	"300"
+//	@Param			colormode		query		string		false	"color, gray or mono"
+//	@Param			pages			query		string		false	"1-5,10,20-"
+//	@Param			stamp			query		bool		false	"true"
+//	@Param			callback		query		string		false	"https://example.com/rinsed"
+//	@Param			Authorization	header		string		false	"JWT token"
+//	@Success		200				{object}	Batch
+//	@Failure		400				{object}	HTTPError
+//	@Failure		403				{object}	HTTPError	"A limit exceeds the quota ceiling."
+//	@Failure		413				{object}	HTTPError	"A file is larger than maxsizemb."
+//	@Failure		415				{object}	HTTPError	"Unsupported content type or document type not allowed."
+//	@Failure		429				{object}	HTTPError	"Queued jobs or disk usage quota exceeded."
+//	@Failure		500				{object}	HTTPError
+//	@Router			/batches [post]
+func (rns *Rinse) RESTPOSTBatches(hw http.ResponseWriter, hr *http.Request) {
+	ctx, span := rns.startHTTPSpan(hr, "RESTPOSTBatches")
+	defer span.End()
+
+	opts, oerr := rns.addJobURLFromQuery(hr)
+	if oerr != nil {
+		SendHTTPError(hw, http.StatusBadRequest, oerr)
+		return
+	}
+
+	email := rns.GetEmail(hr)
+	if err := rns.CheckQuota(email, opts.MaxSizeMB, opts.MaxTimeSec); err != nil {
+		SendHTTPError(hw, quotaHTTPStatus(err, http.StatusForbidden), err)
+		return
+	}
+
+	batchID := uuid.New()
+	var jobs []*Job
+	status := http.StatusBadRequest
+	ct, _, err := mime.ParseMediaType(hr.Header.Get("Content-Type"))
+	if err == nil {
+		switch ct {
+		case "multipart/form-data":
+			jobs, err = rns.addBatchFiles(ctx, hr, batchID, &opts, email)
+			status = batchFilesStatus(err)
+		case "application/json":
+			if err = mustNotBeContentEncoded(hr); err == nil {
+				var items []json.RawMessage
+				if err = ctxShouldBindJSON(hr, &items); err == nil {
+					var addJobUrls []AddJobURL
+					if addJobUrls, err = opts.batchURLs(items); err == nil {
+						status = http.StatusInternalServerError
+						jobs, err = rns.addBatchURLs(ctx, batchID, addJobUrls, email)
+					}
+				}
+			}
+		default:
+			status = http.StatusUnsupportedMediaType
+			err = errors.New(ct)
+		}
+	}
+	if err == nil && len(jobs) == 0 {
+		status = http.StatusBadRequest
+		err = ErrEmptyBatch
+	}
+	if err == nil {
+		status = http.StatusInternalServerError
+		if err = rns.AddJobs(jobs); err == nil {
+			HTTPJSON(hw, http.StatusOK, newBatch(batchID, jobs))
+			return
+		}
+	}
+	for _, job := range jobs {
+		job.Close(err)
+	}
+	rns.Error("RESTPOSTBatches", "batch", batchID, "err", err)
+	SendHTTPError(hw, documentHTTPStatus(err, status), err)
+}
+
+// addBatchFiles returns a new job for each file in the multipart request,
+// reading the files as they arrive. The jobs are not yet queued.
+func (rns *Rinse) addBatchFiles(ctx context.Context, hr *http.Request, batchID uuid.UUID, opts *AddJobURL, email string) (jobs []*Job, err error) {
+	var mr *multipart.Reader
+	if mr, err = hr.MultipartReader(); err == nil {
+		var part *multipart.Part
+		for err == nil {
+			if part, err = mr.NextPart(); err == nil {
+				if part.FormName() == FormFileKey && part.FileName() != "" {
+					var job *Job
+					if job, err = rns.addBatchFile(ctx, batchID, opts, email, part); err == nil {
+						jobs = append(jobs, job)
+					}
+				}
+				_ = part.Close()
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	return
+}
+
+// batchFilesStatus returns the HTTP status code for an error from addBatchFiles.
+// Failing to write a file locally is a server error, while any other error
+// comes from reading a malformed or truncated multipart request.
+func batchFilesStatus(err error) int {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+func (rns *Rinse) addBatchFile(ctx context.Context, batchID uuid.UUID, opts *AddJobURL, email string, part *multipart.Part) (job *Job, err error) {
+	srcName := filepath.Base(part.FileName())
+	var src io.Reader = part
+	maxUploadSize := int64(opts.MaxSizeMB) * 1024 * 1024
+	if maxUploadSize > 0 {
+		src = io.LimitReader(part, maxUploadSize+1)
+	}
+	if job, err = opts.newJob(ctx, rns, srcName, email); err == nil {
+		job.Batch = batchID
+		var dstFile *os.File
+		if dstFile, err = os.Create(filepath.Clean(path.Join(job.Datadir, srcName))); err == nil {
+			var n int64
+			if n, err = io.Copy(dstFile, src); err == nil {
+				if maxUploadSize > 0 && n > maxUploadSize {
+					err = &http.MaxBytesError{Limit: maxUploadSize}
+				} else {
+					err = dstFile.Sync()
+				}
+			}
+			_ = dstFile.Close()
+			if err == nil {
+				_, err = job.DocumentFile()
+			}
+		}
+		if err != nil {
+			job.Close(err)
+			job = nil
+		}
+	}
+	return
+}
+
+// batchURLs decodes and checks each AddJobURL in items, using
+// the options in opts for those missing from an item.
+func (opts *AddJobURL) batchURLs(items []json.RawMessage) (addJobUrls []AddJobURL, err error) {
+	for _, item := range items {
+		addJobUrl := *opts
+		if err = json.Unmarshal(item, &addJobUrl); err == nil {
+			err = addJobUrl.check()
+		}
+		if err != nil {
+			return nil, err
+		}
+		addJobUrls = append(addJobUrls, addJobUrl)
+	}
+	return
+}
+
+// addBatchURLs returns a new job for each of the URLs. The jobs are not yet queued.
+func (rns *Rinse) addBatchURLs(ctx context.Context, batchID uuid.UUID, addJobUrls []AddJobURL, email string) (jobs []*Job, err error) {
+	for _, addJobUrl := range addJobUrls {
+		var job *Job
+		if job, err = addJobUrl.newJob(ctx, rns, addJobUrl.URL, email); err != nil {
+			return
+		}
+		job.Batch = batchID
+		jobs = append(jobs, job)
+	}
+	return
+}
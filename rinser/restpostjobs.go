@@ -8,7 +8,6 @@ import (
 	"os"
 	"path"
 	"path/filepath"
-	"strconv"
 )
 
 // RESTPOSTJobs godoc
@@ -28,60 +27,36 @@ import (
 //	@Param			timeoutsec		query		int			false	"600"
 //	@Param			cleanupgotten	query		bool		false	"true"
 //	@Param			private			query		bool		false	"false"
+//	@Param			formats			query		string		false	"pdfa,txt,hocr,alto,tsv"
+//	@Param			dpi				query		int			false	"300"
+//	@Param			colormode		query		string		false	"color, gray or mono"
+//	@Param			pages			query		string		false	"1-5,10,20-"
+//	@Param			stamp			query		bool		false	"true"
+//	@Param			callback		query		string		false	"https://example.com/rinsed"
 //	@Param			Authorization	header		string		false	"JWT token"
 //	@Success		200				{object}	Job
 //	@Failure		400				{object}	HTTPError
+//	@Failure		403				{object}	HTTPError	"A limit exceeds the quota ceiling."
 //	@Failure		404				{object}	HTTPError
-//	@Failure		415				{object}	HTTPError
+//	@Failure		415				{object}	HTTPError	"Unsupported content type or document type not allowed."
+//	@Failure		429				{object}	HTTPError	"Queued jobs or disk usage quota exceeded."
 //	@Failure		500				{object}	HTTPError
 //	@Router			/jobs [post]
 func (rns *Rinse) RESTPOSTJobs(hw http.ResponseWriter, hr *http.Request) {
-	rns.mu.Lock()
-	maxSizeMB := rns.maxSizeMB
-	maxTimeSec := rns.maxTimeSec
-	cleanupSec := rns.cleanupSec
-	timeoutSec := rns.timeoutSec
-	cleanupGotten := rns.cleanupGotten
-	private := false
-	rns.mu.Unlock()
+	ctx, span := rns.startHTTPSpan(hr, "RESTPOSTJobs")
+	defer span.End()
 
-	if s := hr.URL.Query().Get("maxsizemb"); s != "" {
-		if v, err := strconv.Atoi(s); err == nil {
-			maxSizeMB = v
-		}
-	}
-
-	if s := hr.URL.Query().Get("maxtimesec"); s != "" {
-		if v, err := strconv.Atoi(s); err == nil {
-			maxTimeSec = v
-		}
-	}
-
-	if s := hr.URL.Query().Get("cleanupsec"); s != "" {
-		if v, err := strconv.Atoi(s); err == nil {
-			cleanupSec = v
-		}
-	}
-
-	if s := hr.URL.Query().Get("timeoutsec"); s != "" {
-		if v, err := strconv.Atoi(s); err == nil {
-			timeoutSec = v
-		}
-	}
-
-	if s := hr.URL.Query().Get("cleanupgotten"); s != "" {
-		if v, err := strconv.ParseBool(s); err == nil {
-			cleanupGotten = v
-		}
-	}
-
-	if s := hr.URL.Query().Get("private"); s != "" {
-		if v, err := strconv.ParseBool(s); err == nil {
-			private = v
-		}
+	opts, oerr := rns.addJobURLFromQuery(hr)
+	if oerr != nil {
+		SendHTTPError(hw, http.StatusBadRequest, oerr)
+		return
 	}
 
 	email := rns.GetEmail(hr)
+	if err := rns.CheckQuota(email, opts.MaxSizeMB, opts.MaxTimeSec); err != nil {
+		SendHTTPError(hw, quotaHTTPStatus(err, http.StatusForbidden), err)
+		return
+	}
 
 	ct, _, err := mime.ParseMediaType(hr.Header.Get("Content-Type"))
 	if err == nil {
@@ -91,53 +66,55 @@ func (rns *Rinse) RESTPOSTJobs(hw http.ResponseWriter, hr *http.Request) {
 			if err == nil {
 				srcName := filepath.Base(info.Filename)
 				srcFile := srcFormFile.(io.ReadCloser)
-				if maxUploadSize := int64(maxSizeMB) * 1024 * 1024; maxUploadSize > 0 {
+				if maxUploadSize := int64(opts.MaxSizeMB) * 1024 * 1024; maxUploadSize > 0 {
 					srcFile = http.MaxBytesReader(hw, srcFile, maxUploadSize)
 				}
 				defer srcFile.Close()
-				srcLang := hr.URL.Query().Get("lang")
 				var job *Job
-				if job, err = NewJob(rns, srcName, srcLang, maxSizeMB, maxTimeSec, cleanupSec, timeoutSec, cleanupGotten, private, email); err == nil {
+				if job, err = opts.newJob(ctx, rns, srcName, email); err == nil {
 					dstName := filepath.Clean(path.Join(job.Datadir, srcName))
 					var dstFile *os.File
 					if dstFile, err = os.Create(dstName); err == nil {
 						defer dstFile.Close()
 						if _, err = io.Copy(dstFile, srcFile); err == nil {
 							if err = dstFile.Sync(); err == nil {
-								if err = rns.AddJob(job); err == nil {
-									HTTPJSON(hw, http.StatusOK, job)
-									return
+								if _, err = job.DocumentFile(); err == nil {
+									if err = rns.AddJob(job); err == nil {
+										HTTPJSON(hw, http.StatusOK, job)
+										return
+									}
 								}
 							}
 						}
 					}
 				}
-				rns.Error("RESTPOSTJobs", "job", job.Name, "err", err)
-				SendHTTPError(hw, http.StatusInternalServerError, err)
+				if job != nil {
+					job.Close(err)
+				}
+				rns.Error("RESTPOSTJobs", "name", srcName, "err", err)
+				SendHTTPError(hw, documentHTTPStatus(err, http.StatusInternalServerError), err)
 				return
 			}
 		case "application/json":
 			if err = mustNotBeContentEncoded(hr); err == nil {
-				addJobUrl := AddJobURL{
-					MaxSizeMB:     maxSizeMB,
-					MaxTimeSec:    maxTimeSec,
-					CleanupSec:    cleanupSec,
-					TimeoutSec:    timeoutSec,
-					CleanupGotten: cleanupGotten,
-					Private:       private,
-				}
+				addJobUrl := opts
 				if err = ctxShouldBindJSON(hr, &addJobUrl); err == nil {
+					if err = addJobUrl.check(); err != nil {
+						SendHTTPError(hw, http.StatusBadRequest, err)
+						return
+					}
 					var job *Job
-					if job, err = NewJob(rns, addJobUrl.URL, addJobUrl.Lang,
-						addJobUrl.MaxSizeMB, addJobUrl.MaxTimeSec, addJobUrl.CleanupSec, addJobUrl.TimeoutSec,
-						addJobUrl.CleanupGotten, addJobUrl.Private, email); err == nil {
+					if job, err = addJobUrl.newJob(ctx, rns, addJobUrl.URL, email); err == nil {
 						if err = rns.AddJob(job); err == nil {
 							HTTPJSON(hw, http.StatusOK, job)
 							return
 						}
 					}
-					rns.Error("RESTPOSTJobs", "job", job.Name, "err", err)
-					SendHTTPError(hw, http.StatusInternalServerError, err)
+					if job != nil {
+						job.Close(err)
+					}
+					rns.Error("RESTPOSTJobs", "url", addJobUrl.URL, "err", err)
+					SendHTTPError(hw, quotaHTTPStatus(err, http.StatusInternalServerError), err)
 					return
 				}
 			}
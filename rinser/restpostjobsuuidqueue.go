@@ -0,0 +1,59 @@
+package rinser
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// RESTPOSTJobsUUIDQueue godoc
+//
+//	@Summary		Reorder a queued job
+//	@Description	Set the priority and/or queue position of a job that has not yet started. Requires administrator rights.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			priority		query		int		false	"0"
+//	@Param			position		query		int		false	"0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{object}	Job
+//	@Failure		400				{object}	HTTPError
+//	@Failure		403				{object}	HTTPError
+//	@Failure		404				{object}	HTTPError
+//	@Failure		409				{object}	HTTPError	"Job is not queued."
+//	@Router			/jobs/{uuid}/queue [post]
+func (rns *Rinse) RESTPOSTJobsUUIDQueue(hw http.ResponseWriter, hr *http.Request) {
+	if !rns.IsAdmin(rns.GetEmail(hr)) {
+		SendHTTPError(hw, http.StatusForbidden, nil)
+		return
+	}
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil {
+		priority := job.GetPriority()
+		position := -1
+		var err error
+		if s := hr.URL.Query().Get("priority"); s != "" {
+			priority, err = strconv.Atoi(s)
+		}
+		if s := hr.URL.Query().Get("position"); s != "" && err == nil {
+			position, err = strconv.Atoi(s)
+		}
+		if err != nil {
+			SendHTTPError(hw, http.StatusBadRequest, err)
+			return
+		}
+		if job.State() != JobNew {
+			SendHTTPError(hw, http.StatusConflict, ErrJobNotQueued)
+			return
+		}
+		job.SetPriority(priority)
+		if position >= 0 {
+			if err = rns.MoveJob(job, position); err != nil {
+				SendHTTPError(hw, http.StatusConflict, err)
+				return
+			}
+		}
+		HTTPJSON(hw, http.StatusOK, job)
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
@@ -0,0 +1,39 @@
+package rinser
+
+import "net/http"
+
+// RESTPOSTJobsUUIDRetry godoc
+//
+//	@Summary		Retry a failed job
+//	@Description	Restart a failed job from the stage that failed, reusing the intermediate files.
+//	@Tags			jobs
+//	@Accept			*/*
+//	@Produce		json
+//	@Param			uuid			path		string	true	"49d1e304-d2b8-46bf-b6a6-f1e9b797e1b0"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{object}	Job
+//	@Failure		404				{object}	HTTPError
+//	@Failure		403				{object}	HTTPError	"Job limits exceed the quota."
+//	@Failure		409				{object}	HTTPError	"Job has not failed."
+//	@Failure		429				{object}	HTTPError	"Quota exceeded."
+//	@Failure		500				{object}	HTTPError
+//	@Router			/jobs/{uuid}/retry [post]
+func (rns *Rinse) RESTPOSTJobsUUIDRetry(hw http.ResponseWriter, hr *http.Request) {
+	if job := rns.FindJob(hr.PathValue("uuid")); job != nil {
+		if !job.Retryable() {
+			SendHTTPError(hw, http.StatusConflict, ErrJobNotFailed)
+			return
+		}
+		if err := job.Retry(); err != nil {
+			code := quotaHTTPStatus(err, http.StatusInternalServerError)
+			if code == http.StatusInternalServerError {
+				rns.Error("RESTPOSTJobsUUIDRetry", "job", job.Name, "err", err)
+			}
+			SendHTTPError(hw, code, err)
+			return
+		}
+		HTTPJSON(hw, http.StatusOK, job)
+	} else {
+		SendHTTPError(hw, http.StatusNotFound, nil)
+	}
+}
@@ -0,0 +1,39 @@
+package rinser
+
+import (
+	"net/http"
+)
+
+// RESTPOSTVerify godoc
+//
+//	@Summary		Verify that a PDF was produced by this instance.
+//	@Description	Checks that the PDF in the request body has a valid signature made with the key of this instance
+//	@Description	that covers the whole document, or that it is the rinsed PDF of a job still known.
+//	@Tags			verify
+//	@Accept			application/pdf
+//	@Produce		json
+//	@Param			file			body		string	true	"PDF to verify"
+//	@Param			Authorization	header		string	false	"JWT token"
+//	@Success		200				{object}	Verification
+//	@Failure		400				{object}	HTTPError
+//	@Failure		413				{object}	HTTPError
+//	@Router			/verify [post]
+func (rns *Rinse) RESTPOSTVerify(hw http.ResponseWriter, hr *http.Request) {
+	rns.mu.Lock()
+	maxSizeMB := rns.maxSizeMB
+	rns.mu.Unlock()
+	body := hr.Body
+	if maxUploadSize := int64(maxSizeMB) * 1024 * 1024; maxUploadSize > 0 {
+		body = http.MaxBytesReader(hw, body, maxUploadSize)
+	}
+	v, err := rns.VerifyPDF(body)
+	if err == nil {
+		HTTPJSON(hw, http.StatusOK, v)
+		return
+	}
+	code := http.StatusBadRequest
+	if _, ok := err.(*http.MaxBytesError); ok {
+		code = http.StatusRequestEntityTooLarge
+	}
+	SendHTTPError(hw, code, err)
+}
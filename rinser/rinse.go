@@ -15,6 +15,8 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,6 +28,8 @@ import (
 	"github.com/linkdata/rinse/jwt"
 	"github.com/linkdata/staticserve"
 	"github.com/linkdata/webserv"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/semaphore"
 )
 
 //go:embed assets
@@ -45,19 +49,56 @@ type Rinse struct {
 	RootDir         string
 	FaviconURI      string
 	Languages       []string
-	mu              deadlock.Mutex // protects following
+	metricsHandler  http.Handler
+	tracerProvider  atomic.Pointer[trace.TracerProvider] // receives spans, nil for no tracing
+	tracerShutdown  func(context.Context) error
+	signer          *pdfSigner     // signs rinsed PDFs, nil if not signing
+	eventsMu        deadlock.Mutex // protects eventSubs
+	eventSubs       map[*eventSub]struct{}
+	cacheMu         deadlock.Mutex // serializes result cache changes, protects cacheEvicted
+	cacheEvicted    time.Time
+	ocrSem          *semaphore.Weighted // of maxOcrCPUs, limits concurrent OCR sandboxes
+	ocrMu           deadlock.Mutex      // protects ocrReserved and ocrPending
+	ocrReserved     int64               // part of ocrSem held back to keep to ocrCPUs
+	ocrPending      int64               // part of ocrSem being acquired to add to ocrReserved
+	webhookWg       sync.WaitGroup      // webhook deliveries in progress
+	mu              deadlock.Mutex      // protects following
 	OAuth2Settings  jawsauth.Config
 	closed          bool
+	closeCtx        context.Context // cancelled by Close, nil until first used
+	closeCancel     context.CancelFunc
 	maxSizeMB       int
 	maxTimeSec      int
 	cleanupSec      int
 	timeoutSec      int
 	maxConcurrent   int
+	ocrCPUs         int // max concurrent OCR sandboxes
+	ocrInUse        int
 	cleanupGotten   bool
+	retryMax        int
+	retryDelaySec   int
+	dpi             int
+	colorMode       string
+	maxMegapixels   int // 0 for no cap on rendered page size
+	archiveLimits   ArchiveLimits
+	cacheLimits     CacheLimits
+	clamd           ClamdSettings
+	stamp           StampSettings
 	jobs            []*Job
+	lastStart       map[string]time.Time // when each user last had a job started
+	quotas          map[string]Quota
+	groups          map[string][]string
+	webhooks        []Webhook
+	callbackSecret  string
+	otlpEndpoint    string
+	metricsEnabled  bool
+	metricsToken    string
 	proxyUrl        string
 	externalIP      template.HTML
 	admins          []string // admins from settings
+	pipeline        []string // stage IDs from settings
+	allowedTypes    []string // document MIME types from settings
+	toolVersions    map[string]string
 	endpointForJWKs string
 	JWTPublicKeys   jwt.JSONWebKeySet
 }
@@ -113,11 +154,33 @@ func New(cfg *webserv.Config, mux *http.ServeMux, jw *jaws.Jaws, devel bool) (rn
 									RootDir:    rootDir,
 									FaviconURI: jw.FaviconURL(),
 									jobs:       make([]*Job, 0),
+									lastStart:  make(map[string]time.Time),
 									Languages:  langs,
+									ocrSem:     semaphore.NewWeighted(maxOcrCPUs),
 								}
+								rns.metricsHandler = rns.newMetricsHandler()
 								if e := rns.loadSettings(); e != nil {
 									rns.Error("loadSettings", "file", rns.SettingsFile(), "err", e)
 								}
+								rns.resizeOcrCPUs()
+								if rns.signer, err = loadSigner(cfg.DataDir); err != nil {
+									return
+								} else if rns.signer != nil {
+									rns.Info("signing rinsed PDFs", "subject", rns.signer.cert.Subject.String())
+								}
+								if tp, e := newTracerProvider(rns.otlpEndpoint); tp != nil {
+									rns.SetTracerProvider(tp)
+									rns.tracerShutdown = tp.Shutdown
+								} else if e != nil {
+									rns.Error("newTracerProvider", "endpoint", rns.otlpEndpoint, "err", e)
+								}
+								if err = os.MkdirAll(rns.JobsDir(), 0750); err != nil { // #nosec G301
+									return
+								}
+								if e := rns.loadJobs(); e != nil {
+									rns.Error("loadJobs", "dir", rns.JobsDir(), "err", e)
+								}
+								rns.scrubOrphans()
 								var overrideUrl string
 								if deadlock.Debug {
 									overrideUrl = cfg.ListenURL
@@ -217,30 +280,24 @@ func (rns *Rinse) getClient() *http.Client {
 	return http.DefaultClient
 }
 
-func (rns *Rinse) runTasks() (todo []*Job) {
+func (rns *Rinse) runTasks() (todo, retry []*Job) {
 	rns.mu.Lock()
 	defer rns.mu.Unlock()
-	running := 0
-	var nextJob *Job
 	for _, job := range rns.jobs {
 		switch job.State() {
-		case JobNew:
-			if nextJob == nil {
-				nextJob = job
-			}
 		case JobFailed, JobFinished:
-			if job.CleanupSec >= 0 && time.Since(job.Stopped()) > time.Duration(job.CleanupSec)*time.Second {
-				todo = append(todo, job)
+			if retryAt := job.RetryAt(); !retryAt.IsZero() {
+				if time.Now().After(retryAt) {
+					retry = append(retry, job)
+				}
+			} else if job.CleanupSec >= 0 && time.Since(job.Stopped()) > time.Duration(job.CleanupSec)*time.Second {
+				if rns.childrenDoneLocked(job) {
+					todo = append(todo, job)
+				}
 			}
-		default:
-			running++
-		}
-	}
-	if nextJob != nil && running < rns.maxConcurrent {
-		if err := nextJob.Start(); err != nil {
-			rns.Error("startjob", "job", nextJob.Name, "err", err)
 		}
 	}
+	_ = rns.startNextJobLocked()
 	return
 }
 
@@ -254,9 +311,16 @@ func (rns *Rinse) IsClosed() (yes bool) {
 func (rns *Rinse) runBackgroundTasks() {
 	for !rns.IsClosed() {
 		time.Sleep(time.Second)
-		for _, job := range rns.runTasks() {
+		rns.evictCache()
+		todo, retry := rns.runTasks()
+		for _, job := range todo {
 			rns.RemoveJob(job)
 		}
+		for _, job := range retry {
+			if err := job.retry(true); err != nil {
+				rns.Error("retryjob", "job", job.Name, "err", err)
+			}
+		}
 	}
 }
 
@@ -290,6 +354,7 @@ func (rns *Rinse) addRoutes(mux *http.ServeMux, devel bool) {
 	mux.Handle("GET /{$}", rns.JawsAuth.Handler("index.html", rns))
 	mux.Handle("GET /setup/{$}", rns.JawsAuth.HandlerAdmin("setup.html", rns))
 	mux.Handle("GET /about/{$}", rns.JawsAuth.Handler("about.html", rns))
+	mux.Handle("GET /metrics", http.HandlerFunc(rns.ServeMetrics))
 	mux.Handle("POST /submit", rns.RedirectAuthFn(func(w http.ResponseWriter, r *http.Request) { rns.handlePost(true, w, r) }))
 
 	if !devel {
@@ -299,13 +364,27 @@ func (rns *Rinse) addRoutes(mux *http.ServeMux, devel bool) {
 
 	basePath := ""
 	mux.Handle("GET "+basePath+"/jobs", rns.AuthFn(rns.RESTGETJobs))
+	mux.Handle("GET "+basePath+"/jobs/events", rns.AuthFn(rns.RESTGETJobsEvents))
 	mux.Handle("GET "+basePath+"/jobs/{uuid}", rns.AuthFn(rns.RESTGETJobsUUID))
+	mux.Handle("GET "+basePath+"/jobs/{uuid}/events", rns.AuthFn(rns.RESTGETJobsUUIDEvents))
 	mux.Handle("GET "+basePath+"/jobs/{uuid}/preview", rns.AuthFn(rns.RESTGETJobsUUIDPreview))
 	mux.Handle("GET "+basePath+"/jobs/{uuid}/rinsed", rns.AuthFn(rns.RESTGETJobsUUIDRinsed))
+	mux.Handle("GET "+basePath+"/jobs/{uuid}/combined", rns.AuthFn(rns.RESTGETJobsUUIDCombined))
+	mux.Handle("GET "+basePath+"/jobs/{uuid}/output/{format}", rns.AuthFn(rns.RESTGETJobsUUIDOutputFormat))
+	mux.Handle("GET "+basePath+"/jobs/{uuid}/text", rns.AuthFn(rns.RESTGETJobsUUIDText))
 	mux.Handle("GET "+basePath+"/jobs/{uuid}/meta", rns.AuthFn(rns.RESTGETJobsUUIDMeta))
+	mux.Handle("GET "+basePath+"/jobs/{uuid}/manifest", rns.AuthFn(rns.RESTGETJobsUUIDManifest))
+	mux.Handle("GET "+basePath+"/jobs/{uuid}/signature", rns.AuthFn(rns.RESTGETJobsUUIDSignature))
+	mux.Handle("GET "+basePath+"/jobs/{uuid}/risk", rns.AuthFn(rns.RESTGETJobsUUIDRisk))
 	mux.Handle("GET "+basePath+"/jobs/{uuid}/log", rns.AuthFn(rns.RESTGETJobsUUIDLog))
 	mux.Handle("POST "+basePath+"/jobs", rns.AuthFn(rns.RESTPOSTJobs))
+	mux.Handle("POST "+basePath+"/jobs/{uuid}/retry", rns.AuthFn(rns.RESTPOSTJobsUUIDRetry))
+	mux.Handle("POST "+basePath+"/jobs/{uuid}/queue", rns.AuthFn(rns.RESTPOSTJobsUUIDQueue))
 	mux.Handle("DELETE "+basePath+"/jobs/{uuid}", rns.AuthFn(rns.RESTDELETEJobsUUID))
+	mux.Handle("POST "+basePath+"/batches", rns.AuthFn(rns.RESTPOSTBatches))
+	mux.Handle("GET "+basePath+"/batches/{uuid}", rns.AuthFn(rns.RESTGETBatchesUUID))
+	mux.Handle("GET "+basePath+"/batches/{uuid}/zip", rns.AuthFn(rns.RESTGETBatchesUUIDZip))
+	mux.Handle("POST "+basePath+"/verify", rns.AuthFn(rns.RESTPOSTVerify))
 }
 
 func (rns *Rinse) CleanupSec() (n int) {
@@ -336,16 +415,109 @@ func (rns *Rinse) MaxConcurrent() (n int) {
 	return
 }
 
+// RetryPolicy returns the maximum number of automatic retries of
+// transiently failed jobs and the delay before the first retry.
+func (rns *Rinse) RetryPolicy() (retryMax int, delay time.Duration) {
+	rns.mu.Lock()
+	retryMax = rns.retryMax
+	delay = time.Duration(rns.retryDelaySec) * time.Second
+	rns.mu.Unlock()
+	return
+}
+
+func (rns *Rinse) OcrCPUs() (n int) {
+	rns.mu.Lock()
+	n = rns.ocrCPUs
+	rns.mu.Unlock()
+	return
+}
+
+// maxOcrCPUs is the largest OCR CPU budget.
+const maxOcrCPUs = 1024
+
+// resizeOcrCPUs holds back as much of ocrSem as the OCR CPU budget leaves unused.
+// Shrinking the budget waits for running OCR sandboxes to finish, or for rns to
+// be closed. The budget may change while waiting, so it is checked again after.
+func (rns *Rinse) resizeOcrCPUs() {
+	ctx := rns.closeContext()
+	for {
+		rns.ocrMu.Lock()
+		reserve := int64(maxOcrCPUs - rns.OcrCPUs())
+		if excess := min(rns.ocrReserved, rns.ocrReserved+rns.ocrPending-reserve); excess > 0 {
+			rns.ocrSem.Release(excess)
+			rns.ocrReserved -= excess
+		}
+		need := reserve - rns.ocrReserved - rns.ocrPending
+		if need > 0 {
+			rns.ocrPending += need
+		}
+		rns.ocrMu.Unlock()
+		if need < 1 {
+			return
+		}
+		err := rns.ocrSem.Acquire(ctx, need)
+		rns.ocrMu.Lock()
+		rns.ocrPending -= need
+		if err == nil {
+			rns.ocrReserved += need
+		}
+		rns.ocrMu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// acquireOcrCPU waits until an OCR sandbox may be started without
+// exceeding the OCR CPU budget.
+func (rns *Rinse) acquireOcrCPU(ctx context.Context) (err error) {
+	if err = rns.ocrSem.Acquire(ctx, 1); err == nil {
+		rns.mu.Lock()
+		rns.ocrInUse++
+		rns.mu.Unlock()
+	}
+	return
+}
+
+func (rns *Rinse) releaseOcrCPU() {
+	rns.mu.Lock()
+	rns.ocrInUse--
+	rns.mu.Unlock()
+	rns.ocrSem.Release(1)
+}
+
+// closeContext returns a context that is cancelled when rns is closed.
+func (rns *Rinse) closeContext() context.Context {
+	rns.mu.Lock()
+	defer rns.mu.Unlock()
+	if rns.closeCtx == nil {
+		rns.closeCtx, rns.closeCancel = context.WithCancel(context.Background())
+		if rns.closed {
+			rns.closeCancel()
+		}
+	}
+	return rns.closeCtx
+}
+
 func (rns *Rinse) Close() {
 	rns.mu.Lock()
 	jobs := rns.jobs
 	if !rns.closed {
 		rns.closed = true
 		rns.jobs = nil
+		if rns.closeCancel != nil {
+			rns.closeCancel()
+		}
 	}
 	rns.mu.Unlock()
 	for _, job := range jobs {
-		job.Close(nil)
+		job.stop()
+	}
+	rns.webhookWg.Wait()
+	if rns.tracerShutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = rns.tracerShutdown(ctx)
 	}
 }
 
@@ -389,72 +561,119 @@ func (rns *Rinse) PkgVersion() string {
 	return PkgVersion
 }
 
-func (rns *Rinse) nextJobLocked() (nextJob *Job) {
-	running := 0
-	for _, job := range rns.jobs {
-		switch job.State() {
-		case JobNew:
-			if nextJob == nil {
-				nextJob = job
-			}
-		case JobFailed, JobFinished:
-		default:
-			running++
-			if running >= rns.maxConcurrent {
-				return nil
-			}
-		}
-	}
-	return
-}
-
-func (rns *Rinse) nextJob() (nextJob *Job) {
+func (rns *Rinse) MaybeStartJob() (err error) {
 	rns.mu.Lock()
 	defer rns.mu.Unlock()
-	return rns.nextJobLocked()
+	return rns.startNextJobLocked()
 }
 
-func (rns *Rinse) MaybeStartJob() (err error) {
-	if job := rns.nextJob(); job != nil {
-		err = job.Start()
+func (rns *Rinse) AddJob(job *Job) (err error) {
+	return rns.AddJobs([]*Job{job})
+}
+
+// AddJobs adds the jobs to the queue, or none of them if any can't be added.
+func (rns *Rinse) AddJobs(jobs []*Job) (err error) {
+	for _, job := range jobs {
+		job.refreshDiskuse()
+	}
+	if err = rns.addJobs(jobs); err == nil {
+		for _, job := range jobs {
+			if job.Parent == uuid.Nil {
+				job.mu.Lock()
+				metricBytesIn.Add(float64(job.Diskuse))
+				job.mu.Unlock()
+			}
+			job.save()
+		}
+		_ = rns.MaybeStartJob()
+		rns.Jaws.Dirty(rns, uiQueue{rns})
 	}
 	return
 }
 
-func (rns *Rinse) AddJob(job *Job) (err error) {
+func (rns *Rinse) addJobs(jobs []*Job) (err error) {
 	rns.mu.Lock()
 	defer rns.mu.Unlock()
 	err = http.ErrServerClosed
 	if !rns.closed {
-		err = ErrDuplicateUUID
-		for _, j := range rns.jobs {
-			if job.UUID == j.UUID {
+		n := len(rns.jobs)
+		for _, job := range jobs {
+			if err = rns.checkAddJobLocked(job); err != nil {
+				rns.jobs = slices.Delete(rns.jobs, n, len(rns.jobs))
 				return
 			}
+			rns.jobs = append(rns.jobs, job)
 		}
-		err = nil
-		rns.jobs = append(rns.jobs, job)
-		if nextJob := rns.nextJobLocked(); nextJob != nil {
-			_ = nextJob.Start()
-		}
-		rns.Jaws.Dirty(rns)
 	}
 	return
 }
 
+// checkAddJobLocked returns an error if the job may not be added to the queue.
+func (rns *Rinse) checkAddJobLocked(job *Job) (err error) {
+	for _, j := range rns.jobs {
+		if job.UUID == j.UUID {
+			return ErrDuplicateUUID
+		}
+	}
+	job.mu.Lock()
+	diskuse := job.Diskuse
+	job.mu.Unlock()
+	if job.Parent == uuid.Nil {
+		return rns.checkQuotaLocked(job.Email, job.MaxSizeMB, job.MaxTimeSec, diskuse)
+	}
+	return rns.checkChildQuotaLocked(job.Email, diskuse)
+}
+
+// RemoveJob removes the job and any jobs unpacked from it.
 func (rns *Rinse) RemoveJob(job *Job) {
+	var removed []*Job
 	rns.mu.Lock()
-	rns.jobs = slices.DeleteFunc(rns.jobs, func(x *Job) bool { return x == job })
+	rns.jobs = slices.DeleteFunc(rns.jobs, func(x *Job) bool {
+		if x == job || x.Parent == job.UUID {
+			removed = append(removed, x)
+			return true
+		}
+		return false
+	})
 	rns.mu.Unlock()
-	job.Close(nil)
-	rns.Jaws.Dirty(rns)
+	for _, x := range removed {
+		x.Close(nil)
+	}
+	rns.Jaws.Dirty(rns, uiQueue{rns})
+}
+
+// Children returns the jobs unpacked from the job.
+func (rns *Rinse) Children(job *Job) []*Job {
+	rns.mu.Lock()
+	defer rns.mu.Unlock()
+	return rns.childrenLocked(job)
+}
+
+func (rns *Rinse) childrenLocked(job *Job) (children []*Job) {
+	for _, x := range rns.jobs {
+		if x.Parent == job.UUID {
+			children = append(children, x)
+		}
+	}
+	return
+}
+
+// childrenDoneLocked returns true if none of the jobs unpacked
+// from the job are waiting or running.
+func (rns *Rinse) childrenDoneLocked(job *Job) bool {
+	for _, x := range rns.childrenLocked(job) {
+		if state := x.State(); state != JobFinished && state != JobFailed {
+			return false
+		}
+	}
+	return true
 }
 
 // JawsContains implements jaws.Container.
 func (rns *Rinse) JawsContains(e *jaws.Element) (contents []jaws.UI) {
 	sortedJobs := rns.JobList(rns.GetEmail(e.Initial()))
 	slices.SortFunc(sortedJobs, func(a, b *Job) int { return b.Created.Compare(a.Created) })
-	for _, job := range sortedJobs {
+	for _, job := range groupJobs(sortedJobs) {
 		contents = append(contents, ui.NewTemplate("", "job.html", job))
 	}
 	return
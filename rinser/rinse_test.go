@@ -0,0 +1,92 @@
+package rinser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+func TestOcrCPUs(t *testing.T) {
+	rns := &Rinse{ocrSem: semaphore.NewWeighted(maxOcrCPUs), ocrCPUs: 2}
+	rns.resizeOcrCPUs()
+	ctx := context.Background()
+	for range 2 {
+		if err := rns.acquireOcrCPU(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := rns.acquireOcrCPU(short); err == nil {
+		t.Fatal("acquired more than the budget")
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- rns.acquireOcrCPU(ctx) }()
+	rns.mu.Lock()
+	rns.ocrCPUs = 3
+	rns.mu.Unlock()
+	rns.resizeOcrCPUs()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("growing the budget did not let a waiting sandbox start")
+	}
+
+	rns.mu.Lock()
+	rns.ocrCPUs = 1
+	rns.mu.Unlock()
+	resized := make(chan struct{})
+	go func() {
+		rns.resizeOcrCPUs()
+		close(resized)
+	}()
+	for range 3 {
+		rns.releaseOcrCPU()
+	}
+	<-resized
+	if err := rns.acquireOcrCPU(ctx); err != nil {
+		t.Fatal(err)
+	}
+	short, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := rns.acquireOcrCPU(short); err == nil {
+		t.Error("acquired more than the shrunk budget")
+	}
+	if rns.ocrInUse != 1 {
+		t.Errorf("ocrInUse %d", rns.ocrInUse)
+	}
+}
+
+func TestOcrCPUsClose(t *testing.T) {
+	rns := &Rinse{ocrSem: semaphore.NewWeighted(maxOcrCPUs), ocrCPUs: 2}
+	rns.resizeOcrCPUs()
+	ctx := context.Background()
+	for range 2 {
+		if err := rns.acquireOcrCPU(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rns.mu.Lock()
+	rns.ocrCPUs = 1
+	rns.mu.Unlock()
+	resized := make(chan struct{})
+	go func() {
+		rns.resizeOcrCPUs()
+		close(resized)
+	}()
+	rns.Close()
+	select {
+	case <-resized:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shrinking the budget still waits after Close")
+	}
+	if rns.ocrPending != 0 || rns.ocrReserved != maxOcrCPUs-2 {
+		t.Errorf("ocrReserved %d ocrPending %d", rns.ocrReserved, rns.ocrPending)
+	}
+}
@@ -4,23 +4,31 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path"
+	"slices"
 	"text/template"
 	"time"
 )
 
 type configJsonData struct {
 	Args        string
+	Env         string
 	RootDir     string
 	VarRinseDir string
 	Uid         int
 	Gid         int
 }
 
+var defaultEnv = []string{
+	"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+	"HOME=/home/rinse",
+}
+
 func mustJson(obj any) string {
 	b, err := json.Marshal(obj)
 	if err == nil {
@@ -29,16 +37,24 @@ func mustJson(obj any) string {
 	panic(err)
 }
 
+var ErrSandboxStart = errors.New("sandbox failed to start")
+
 var configJsonTmpl = template.Must(template.New("config.tmpl").ParseFS(assetsFS, "assets/config.tmpl"))
 
 func runsc(ctx context.Context, runscBin, rootfsDir, workDir, logPath string, id string, outhandler func(string, bool) error, cmds ...string) (err error) {
+	return runscBundle(ctx, runscBin, rootfsDir, workDir, path.Join(workDir, "data"), logPath, id, nil, outhandler, cmds...)
+}
+
+// runscBundle runs cmds in a sandbox using bundleDir for the OCI bundle and
+// mounting varRinseDir as /var/rinse. The env strings are added to the default
+// environment.
+func runscBundle(ctx context.Context, runscBin, rootfsDir, bundleDir, varRinseDir, logPath string, id string, env []string, outhandler func(string, bool) error, cmds ...string) (err error) {
 	var logfile *os.File
 	if logfile, err = os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil /* #nosec G304 */ {
 		defer logfile.Close()
 		var f *os.File
-		if f, err = os.Create(path.Join(workDir, "config.json")); err == nil /* #nosec G304 */ {
+		if f, err = os.Create(path.Join(bundleDir, "config.json")); err == nil /* #nosec G304 */ {
 			defer f.Close()
-			varRinseDir := path.Join(workDir, "data")
 			isRoot := os.Getuid() == 0
 			var uidgid int
 			if isRoot {
@@ -46,6 +62,7 @@ func runsc(ctx context.Context, runscBin, rootfsDir, workDir, logPath string, id
 			}
 			cfg := &configJsonData{
 				Args:        mustJson(cmds),
+				Env:         mustJson(append(slices.Clone(defaultEnv), env...)),
 				RootDir:     mustJson(rootfsDir),
 				VarRinseDir: mustJson(varRinseDir),
 				Uid:         uidgid,
@@ -59,10 +76,10 @@ func runsc(ctx context.Context, runscBin, rootfsDir, workDir, logPath string, id
 							if !isRoot {
 								runscargs = append(runscargs, "-rootless")
 							}
-							runscargs = append(runscargs, "run", "-bundle", workDir, id)
+							runscargs = append(runscargs, "run", "-bundle", bundleDir, id)
 							fmt.Fprintf(logfile, "%v %s %v %v\n", time.Now().UTC().Format(time.DateTime), runscBin, runscargs, cmds)
 							cmd := exec.Command(runscBin, runscargs...) // #nosec G204
-							cmd.Dir = workDir
+							cmd.Dir = bundleDir
 							defer func() {
 								if cmd.Process != nil {
 									if cmd.ProcessState == nil || !cmd.ProcessState.Exited() {
@@ -128,6 +145,8 @@ func runsc(ctx context.Context, runscBin, rootfsDir, workDir, logPath string, id
 												err = ctx.Err()
 											}
 										}
+									} else {
+										err = fmt.Errorf("%w: %w", ErrSandboxStart, err)
 									}
 								}
 							}
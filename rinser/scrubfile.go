@@ -36,5 +36,8 @@ func scrub(dstpath string) error {
 		errs = append(errs, err)
 	}
 	errs = append(errs, os.RemoveAll(dstpath))
-	return errors.Join(errs...)
+	if err = errors.Join(errs...); err != nil {
+		metricScrubErrors.Inc()
+	}
+	return err
 }
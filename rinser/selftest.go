@@ -41,7 +41,7 @@ func (rns *Rinse) SelfTest() int {
 							select {
 							case <-to.C:
 								err = errors.New("timeout")
-							case <-job.StoppedCh:
+							case <-job.StoppedCh():
 								if err = job.Error; err == nil {
 									if job.HasMeta() {
 										if job.HasLog() {
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"os"
 	"path"
+	"runtime"
+	"slices"
 
 	"github.com/linkdata/jawsauth"
 )
@@ -15,10 +17,29 @@ type settings struct {
 	MaxTimeSec      int
 	TimeoutSec      int
 	MaxConcurrent   int
+	OcrCPUs         int
+	RetryMax        int
+	RetryDelaySec   int
+	Dpi             int
+	ColorMode       string
+	MaxMegapixels   int
+	Archive         ArchiveLimits
+	Cache           CacheLimits
+	Clamd           ClamdSettings
+	Stamp           StampSettings
 	CleanupGotten   bool
 	OAuth2          jawsauth.Config
 	ProxyURL        string
 	Admins          []string
+	Pipeline        []string            // stage IDs, empty for DefaultPipeline
+	AllowedTypes    []string            // document MIME types, empty for DefaultAllowedTypes
+	Quotas          map[string]Quota    `json:",omitempty"` // keyed by email, group name or "*"
+	Groups          map[string][]string `json:",omitempty"` // group name to member emails or "@domain"
+	Webhooks        []Webhook           `json:",omitempty"`
+	CallbackSecret  string              `json:",omitempty"` // signs payloads sent to job callback URLs
+	MetricsEnabled  bool
+	MetricsToken    string `json:",omitempty"` // bearer token required to read /metrics
+	OTLPEndpoint    string `json:",omitempty"` // OTLP/HTTP trace collector, e.g. http://localhost:4318
 	EndpointForJWKs string // endpoint for getting JWKs used for JWT verification e.g. {keycloak-root-endpoint}/realms/{realm-name}/protocol/openid-connect/certs
 }
 
@@ -29,15 +50,34 @@ func (rns *Rinse) SettingsFile() string {
 func (rns *Rinse) saveSettings() (err error) {
 	rns.mu.Lock()
 	x := settings{
-		MaxSizeMB:     rns.maxSizeMB,
-		CleanupSec:    rns.cleanupSec,
-		MaxTimeSec:    rns.maxTimeSec,
-		TimeoutSec:    rns.timeoutSec,
-		MaxConcurrent: rns.maxConcurrent,
-		CleanupGotten: rns.cleanupGotten,
-		OAuth2:        rns.OAuth2Settings,
-		ProxyURL:      rns.proxyUrl,
-		Admins:        rns.getAdmins(),
+		MaxSizeMB:      rns.maxSizeMB,
+		CleanupSec:     rns.cleanupSec,
+		MaxTimeSec:     rns.maxTimeSec,
+		TimeoutSec:     rns.timeoutSec,
+		MaxConcurrent:  rns.maxConcurrent,
+		OcrCPUs:        rns.ocrCPUs,
+		RetryMax:       rns.retryMax,
+		RetryDelaySec:  rns.retryDelaySec,
+		Dpi:            rns.dpi,
+		ColorMode:      rns.colorMode,
+		MaxMegapixels:  rns.maxMegapixels,
+		Archive:        rns.archiveLimits,
+		Cache:          rns.cacheLimits,
+		Clamd:          rns.clamd,
+		Stamp:          rns.stamp,
+		CleanupGotten:  rns.cleanupGotten,
+		OAuth2:         rns.OAuth2Settings,
+		ProxyURL:       rns.proxyUrl,
+		Admins:         rns.getAdmins(),
+		Pipeline:       rns.pipeline,
+		AllowedTypes:   rns.allowedTypes,
+		Quotas:         rns.quotas,
+		Groups:         rns.groups,
+		Webhooks:       rns.webhooks,
+		CallbackSecret: rns.callbackSecret,
+		MetricsEnabled: rns.metricsEnabled,
+		MetricsToken:   rns.metricsToken,
+		OTLPEndpoint:   rns.otlpEndpoint,
 	}
 	rns.mu.Unlock()
 	var b []byte
@@ -54,6 +94,16 @@ func (rns *Rinse) loadSettings() (err error) {
 		MaxTimeSec:    86400,
 		TimeoutSec:    60,
 		MaxConcurrent: 2,
+		OcrCPUs:       runtime.NumCPU(),
+		RetryMax:      2,
+		RetryDelaySec: 60,
+		Dpi:           150,
+		ColorMode:     "color",
+		MaxMegapixels: 40,
+		Archive:       ArchiveLimits{MaxFiles: 1000, MaxRatio: 100, MaxDepth: 1},
+		Cache:         CacheLimits{TTLSec: 86400},
+		Clamd:         ClamdSettings{Policy: ScanReject, TimeoutSec: 120},
+		Stamp:         StampSettings{Template: DefaultStampTemplate, Position: StampFooter},
 		CleanupGotten: true,
 	}
 	var b []byte
@@ -70,10 +120,77 @@ func (rns *Rinse) loadSettings() (err error) {
 	rns.maxTimeSec = max(0, x.MaxTimeSec)
 	rns.timeoutSec = max(0, x.TimeoutSec)
 	rns.maxConcurrent = max(1, x.MaxConcurrent)
+	rns.ocrCPUs = min(maxOcrCPUs, max(1, x.OcrCPUs))
+	rns.retryMax = max(0, x.RetryMax)
+	rns.retryDelaySec = max(0, x.RetryDelaySec)
+	rns.dpi = min(MaxDpi, max(MinDpi, x.Dpi))
+	rns.colorMode = ColorModes[0]
+	if slices.Contains(ColorModes, x.ColorMode) {
+		rns.colorMode = x.ColorMode
+	}
+	rns.maxMegapixels = max(0, x.MaxMegapixels)
+	rns.archiveLimits = ArchiveLimits{
+		MaxFiles: max(0, x.Archive.MaxFiles),
+		MaxRatio: max(0, x.Archive.MaxRatio),
+		MaxDepth: max(0, x.Archive.MaxDepth),
+	}
+	rns.cacheLimits = CacheLimits{
+		MaxSizeMB: max(0, x.Cache.MaxSizeMB),
+		TTLSec:    max(0, x.Cache.TTLSec),
+		Private:   x.Cache.Private,
+	}
+	rns.clamd = ClamdSettings{
+		Address:    x.Clamd.Address,
+		Policy:     ScanReject,
+		TimeoutSec: max(0, x.Clamd.TimeoutSec),
+	}
+	if e := CheckScanPolicy(x.Clamd.Policy); e == nil {
+		rns.clamd.Policy = x.Clamd.Policy
+	} else {
+		rns.Config.Logger.Error("loadSettings", "clamd", x.Clamd.Policy, "err", e)
+	}
+	rns.stamp = StampSettings{Enabled: x.Stamp.Enabled, Template: DefaultStampTemplate, Position: StampFooter}
+	if e := CheckStampSettings(x.Stamp); e == nil {
+		rns.stamp = x.Stamp
+	} else {
+		rns.Config.Logger.Error("loadSettings", "stamp", x.Stamp, "err", e)
+	}
 	rns.cleanupGotten = x.CleanupGotten
 	rns.OAuth2Settings = x.OAuth2
 	rns.proxyUrl = x.ProxyURL
 	rns.admins = x.Admins
 	rns.endpointForJWKs = x.EndpointForJWKs
+	rns.quotas = x.Quotas
+	rns.groups = x.Groups
+	rns.webhooks = nil
+	for _, hook := range x.Webhooks {
+		e := CheckCallback(hook.URL)
+		if e == nil && hook.URL == "" {
+			e = ErrIllegalCallback
+		}
+		if e == nil {
+			rns.webhooks = append(rns.webhooks, hook)
+		} else {
+			rns.Config.Logger.Error("loadSettings", "webhook", hook.URL, "err", e)
+		}
+	}
+	rns.callbackSecret = x.CallbackSecret
+	rns.metricsEnabled = x.MetricsEnabled
+	rns.metricsToken = x.MetricsToken
+	rns.otlpEndpoint = x.OTLPEndpoint
+	rns.pipeline = nil
+	if len(x.Pipeline) > 0 {
+		if _, e := lookupStages(x.Pipeline); e == nil {
+			rns.pipeline = x.Pipeline
+		} else {
+			rns.Config.Logger.Error("loadSettings", "pipeline", x.Pipeline, "err", e)
+		}
+	}
+	rns.allowedTypes = nil
+	if e := CheckAllowedTypes(x.AllowedTypes); e == nil {
+		rns.allowedTypes = x.AllowedTypes
+	} else {
+		rns.Config.Logger.Error("loadSettings", "allowedtypes", x.AllowedTypes, "err", e)
+	}
 	return
 }
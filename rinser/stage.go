@@ -0,0 +1,231 @@
+package rinser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/linkdata/deadlock"
+)
+
+// Stage is one step of the job processing pipeline.
+//
+// While a stage runs, the job is in the JobState assigned
+// to the stage when it was registered.
+type Stage interface {
+	// Name returns the job state text shown while the stage runs.
+	Name() string
+	// Run performs the stage work on the job.
+	Run(ctx context.Context, job *Job) error
+}
+
+var ErrUnknownStage = errors.New("unknown stage")
+var ErrDuplicateStage = errors.New("duplicate stage")
+var ErrEmptyPipeline = errors.New("empty pipeline")
+var ErrStageNeeded = errors.New("stage needs an earlier stage")
+
+// ErrPipelineDone may be returned by a stage to finish
+// the job successfully without running the remaining stages.
+var ErrPipelineDone = errors.New("pipeline done")
+
+type registeredStage struct {
+	Stage
+	id    string
+	state JobState
+	needs []string // stages that must run before this one
+}
+
+var (
+	stagesMu        deadlock.Mutex // protects following
+	stagesByID      = map[string]*registeredStage{}
+	stagesByState   = map[JobState]*registeredStage{}
+	nextCustomState = JobStamp + 1
+)
+
+// stateIDs are the IDs of the job states that don't belong to a stage.
+var stateIDs = map[JobState]string{
+	JobNew:      "new",
+	JobStarting: "starting",
+	JobFinished: "finished",
+	JobFailed:   "failed",
+}
+
+// stageFunc adapts a Job method to the Stage interface.
+type stageFunc struct {
+	name string
+	fn   func(*Job, context.Context) error
+}
+
+func (s stageFunc) Name() string {
+	return s.name
+}
+
+func (s stageFunc) Run(ctx context.Context, job *Job) error {
+	return s.fn(job, ctx)
+}
+
+// DefaultPipeline lists the stage IDs used when no pipeline is configured.
+var DefaultPipeline = []string{"download", "scan", "unpack", "cache", "meta", "language", "doctopdf", "pdftoimages", "stamp", "tesseract", "ending"}
+
+func init() {
+	mustRegisterStage("download", JobDownload, stageFunc{"Downloading", (*Job).runDownload})
+	mustRegisterStage("scan", JobScan, stageFunc{"Virus Scan", (*Job).runScan})
+	mustRegisterStage("unpack", JobUnpack, stageFunc{"Unpacking", (*Job).runUnpack})
+	mustRegisterStage("cache", JobCache, stageFunc{"Checking Cache", (*Job).runCache})
+	mustRegisterStage("meta", JobExtractMeta, stageFunc{"Extract Metadata", (*Job).runExtractMeta})
+	mustRegisterStage("language", JobDetectLanguage, stageFunc{"Detect Language", (*Job).runDetectLanguage})
+	mustRegisterStage("doctopdf", JobDocToPdf, stageFunc{"Converting", (*Job).runDocToPdf})
+	mustRegisterStage("pdftoimages", JobPdfToImages, stageFunc{"Rendering", (*Job).runPdfToImages}, "doctopdf")
+	mustRegisterStage("stamp", JobStamp, stageFunc{"Stamping", (*Job).runStamp}, "pdftoimages")
+	mustRegisterStage("tesseract", JobTesseract, stageFunc{"Scanning", (*Job).runTesseract}, "pdftoimages")
+	mustRegisterStage("ending", JobEnding, stageFunc{"Cleanup", (*Job).jobEnding}, "tesseract")
+}
+
+func registerStageLocked(id string, state JobState, stage Stage, needs []string) (err error) {
+	err = ErrDuplicateStage
+	if _, ok := stagesByID[id]; !ok && !isStateID(id) {
+		err = nil
+		rs := &registeredStage{Stage: stage, id: id, state: state, needs: needs}
+		stagesByID[id] = rs
+		stagesByState[state] = rs
+	}
+	return
+}
+
+func mustRegisterStage(id string, state JobState, stage Stage, needs ...string) {
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	if err := registerStageLocked(id, state, stage, needs); err != nil {
+		panic(fmt.Errorf("%w: %q", err, id))
+	}
+}
+
+// RegisterStage adds a stage to the registry so that it may be used in a pipeline,
+// and returns the JobState jobs have while the stage runs.
+func RegisterStage(id string, stage Stage) (state JobState, err error) {
+	err = ErrUnknownStage
+	if id = strings.TrimSpace(id); id != "" && stage != nil {
+		stagesMu.Lock()
+		defer stagesMu.Unlock()
+		if err = registerStageLocked(id, nextCustomState, stage, nil); err == nil {
+			state = nextCustomState
+			nextCustomState++
+		}
+	}
+	return
+}
+
+// StageIDs returns the IDs of all registered stages, sorted.
+func StageIDs() (ids []string) {
+	stagesMu.Lock()
+	for id := range stagesByID {
+		ids = append(ids, id)
+	}
+	stagesMu.Unlock()
+	slices.Sort(ids)
+	return
+}
+
+func stageName(state JobState) (s string, ok bool) {
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	var rs *registeredStage
+	if rs, ok = stagesByState[state]; ok {
+		s = rs.Name()
+	}
+	return
+}
+
+// stateID returns the ID a job state is persisted as, which is the stage ID
+// for the state of a stage, since custom stages are numbered in the order they
+// are registered. Returns false if the state is unknown.
+func stateID(state JobState) (id string, ok bool) {
+	if id, ok = stateIDs[state]; !ok {
+		stagesMu.Lock()
+		defer stagesMu.Unlock()
+		var rs *registeredStage
+		if rs, ok = stagesByState[state]; ok {
+			id = rs.id
+		}
+	}
+	return
+}
+
+// isStateID returns true if id is the ID of a job state that doesn't belong to a stage.
+func isStateID(id string) bool {
+	for _, s := range stateIDs {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
+// idState returns the job state persisted as id.
+func idState(id string) (state JobState, err error) {
+	for state, s := range stateIDs {
+		if s == id {
+			return state, nil
+		}
+	}
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	if rs, ok := stagesByID[id]; ok {
+		return rs.state, nil
+	}
+	return JobNew, fmt.Errorf("%w: %q", ErrUnknownStage, id)
+}
+
+func lookupStages(ids []string) (stages []*registeredStage, err error) {
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	err = ErrEmptyPipeline
+	for _, id := range ids {
+		err = nil
+		rs, ok := stagesByID[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownStage, id)
+		}
+		if slices.Contains(stages, rs) {
+			return nil, fmt.Errorf("%w: %q", ErrDuplicateStage, id)
+		}
+		for _, need := range rs.needs {
+			if !slices.Contains(ids[:len(stages)], need) {
+				return nil, fmt.Errorf("%w: %q needs %q", ErrStageNeeded, id, need)
+			}
+		}
+		stages = append(stages, rs)
+	}
+	return
+}
+
+// Pipeline returns the IDs of the stages jobs run through, in order.
+func (rns *Rinse) Pipeline() (ids []string) {
+	rns.mu.Lock()
+	ids = slices.Clone(rns.pipeline)
+	rns.mu.Unlock()
+	if len(ids) == 0 {
+		ids = slices.Clone(DefaultPipeline)
+	}
+	return
+}
+
+// SetPipeline sets the stages that new jobs will run through.
+// An empty list restores the DefaultPipeline.
+func (rns *Rinse) SetPipeline(ids []string) (err error) {
+	if len(ids) > 0 {
+		_, err = lookupStages(ids)
+	}
+	if err == nil {
+		rns.mu.Lock()
+		rns.pipeline = slices.Clone(ids)
+		rns.mu.Unlock()
+	}
+	return
+}
+
+func (rns *Rinse) pipelineStages() ([]*registeredStage, error) {
+	return lookupStages(rns.Pipeline())
+}
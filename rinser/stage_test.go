@@ -0,0 +1,82 @@
+package rinser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const stageTestStage = "stagetest"
+
+var registerStageTestStage = sync.OnceValues(func() (JobState, error) {
+	return RegisterStage(stageTestStage, stageFunc{"Testing", func(*Job, context.Context) error { return nil }})
+})
+
+func TestPersistedState(t *testing.T) {
+	custom, err := registerStageTestStage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range []JobState{JobNew, JobStarting, JobFinished, JobFailed, JobTesseract, custom} {
+		b, err := json.Marshal(persistedState(state))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(b), `"`) {
+			t.Errorf("%s persisted as %s", jobStateText(state), b)
+		}
+		var ps persistedState
+		if err = json.Unmarshal(b, &ps); err != nil || JobState(ps) != state {
+			t.Errorf("%s loaded as %d: %v", b, ps, err)
+		}
+	}
+
+	var ps persistedState
+	if err = json.Unmarshal([]byte(`"nosuchstage"`), &ps); !errors.Is(err, ErrUnknownStage) {
+		t.Errorf("unknown stage: %v", err)
+	}
+	if err = json.Unmarshal([]byte(`7`), &ps); err == nil {
+		t.Errorf("numeric state loaded as %d", ps)
+	}
+	if _, err = RegisterStage("failed", stageFunc{"Failing", nil}); !errors.Is(err, ErrDuplicateStage) {
+		t.Errorf("stage with the ID of a job state: %v", err)
+	}
+}
+
+func TestSetPipeline(t *testing.T) {
+	if _, err := registerStageTestStage(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		ids []string
+		err error
+	}{
+		{nil, nil},
+		{DefaultPipeline, nil},
+		{[]string{"download", "doctopdf", "pdftoimages", "tesseract", "ending"}, nil},
+		{[]string{"download", stageTestStage}, nil},
+		{[]string{"download", "nosuchstage"}, ErrUnknownStage},
+		{[]string{"download", "download"}, ErrDuplicateStage},
+		{[]string{"download", "doctopdf", "pdftoimages", "ending"}, ErrStageNeeded},
+		{[]string{"download", "doctopdf", "tesseract", "pdftoimages", "ending"}, ErrStageNeeded},
+		{[]string{"download", "pdftoimages", "tesseract", "ending"}, ErrStageNeeded},
+	} {
+		rns := &Rinse{}
+		err := rns.SetPipeline(tc.ids)
+		if !errors.Is(err, tc.err) || (tc.err == nil && err != nil) {
+			t.Errorf("%q: err %v, want %v", tc.ids, err, tc.err)
+		}
+		if want := tc.ids; err == nil {
+			if len(want) == 0 {
+				want = DefaultPipeline
+			}
+			if !slices.Equal(rns.Pipeline(), want) {
+				t.Errorf("%q: pipeline %q", tc.ids, rns.Pipeline())
+			}
+		}
+	}
+}
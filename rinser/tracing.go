@@ -0,0 +1,92 @@
+package rinser
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/linkdata/rinse/rinser"
+
+// tracePropagator reads and writes W3C traceparent headers.
+var tracePropagator = propagation.TraceContext{}
+
+// newTracerProvider returns a TracerProvider exporting spans using OTLP over HTTP
+// to endpoint, such as "http://collector:4318", or nil if endpoint is empty.
+func newTracerProvider(endpoint string) (tp *sdktrace.TracerProvider, err error) {
+	if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+		var exporter sdktrace.SpanExporter
+		if exporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint)); err == nil {
+			tp = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+		}
+	}
+	return
+}
+
+// TracerProvider returns the provider receiving spans, or nil if not tracing.
+func (rns *Rinse) TracerProvider() (tp trace.TracerProvider) {
+	if p := rns.tracerProvider.Load(); p != nil {
+		tp = *p
+	}
+	return
+}
+
+// SetTracerProvider sets the provider receiving spans, nil to stop tracing.
+// It is safe to call while jobs are running.
+func (rns *Rinse) SetTracerProvider(tp trace.TracerProvider) {
+	rns.tracerProvider.Store(&tp)
+}
+
+func (rns *Rinse) tracer() trace.Tracer {
+	tp := rns.TracerProvider()
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	return tp.Tracer(tracerName, trace.WithInstrumentationVersion(PkgVersion))
+}
+
+// startHTTPSpan starts a server span for the request, continuing
+// the trace given in the request's traceparent header, if any.
+func (rns *Rinse) startHTTPSpan(hr *http.Request, name string) (context.Context, trace.Span) {
+	ctx := tracePropagator.Extract(hr.Context(), propagation.HeaderCarrier(hr.Header))
+	return rns.tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", hr.Method),
+			attribute.String("url.path", hr.URL.Path),
+		))
+}
+
+// startSpan starts a span that is part of the trace of the request that added the job.
+func (job *Job) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, job.spanContext)
+	}
+	opts = append(opts, trace.WithAttributes(
+		attribute.String("rinse.job.uuid", job.UUID.String()),
+		attribute.String("rinse.job.name", job.Name),
+	))
+	return job.Rinse.tracer().Start(ctx, name, opts...)
+}
+
+// injectTrace adds the traceparent header of the span in ctx to the request.
+func injectTrace(ctx context.Context, req *http.Request) {
+	tracePropagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// endSpan records err, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
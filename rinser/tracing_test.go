@@ -0,0 +1,197 @@
+package rinser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/linkdata/jaws"
+	"github.com/linkdata/webserv"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceTestStage = "tracesandbox"
+
+var registerTraceTestStage = sync.OnceValue(func() error {
+	_, err := RegisterStage(traceTestStage, stageFunc{"Sandboxing", func(job *Job, ctx context.Context) error {
+		_ = job.runsc(ctx, nil, "echo", "hello")
+		return nil
+	}})
+	return err
+})
+
+// traceparents records the traceparent header of each request it serves.
+type traceparents struct {
+	mu   sync.Mutex
+	seen []string
+}
+
+func (tps *traceparents) handler(body string) http.HandlerFunc {
+	return func(hw http.ResponseWriter, hr *http.Request) {
+		tps.mu.Lock()
+		tps.seen = append(tps.seen, hr.Header.Get("traceparent"))
+		tps.mu.Unlock()
+		_, _ = hw.Write([]byte(body))
+	}
+}
+
+func (tps *traceparents) get() []string {
+	tps.mu.Lock()
+	defer tps.mu.Unlock()
+	return slices.Clone(tps.seen)
+}
+
+func traceparent(sc trace.SpanContext) string {
+	return "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+}
+
+func spanAttr(span sdktrace.ReadOnlySpan, key attribute.Key) (v attribute.Value) {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			v = kv.Value
+		}
+	}
+	return
+}
+
+func TestTraceJob(t *testing.T) {
+	if err := registerTraceTestStage(); err != nil {
+		t.Fatal(err)
+	}
+	var downloads, webhooks traceparents
+	docSrv := httptest.NewServer(downloads.handler("%PDF-1.4\n"))
+	defer docSrv.Close()
+	hookSrv := httptest.NewServer(webhooks.handler(""))
+	defer hookSrv.Close()
+
+	dataDir := t.TempDir()
+	if err := os.Mkdir(path.Join(dataDir, "jobs"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	runscBin := path.Join(dataDir, "runsc")
+	if err := os.WriteFile(runscBin, []byte("#!/bin/sh\nexit 3\n"), 0o700); err != nil /* #nosec G306 */ {
+		t.Fatal(err)
+	}
+	jw, err := jaws.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jw.Close()
+	rns := &Rinse{
+		Config:    &webserv.Config{DataDir: dataDir},
+		Jaws:      jw,
+		RunscBin:  runscBin,
+		RootDir:   dataDir,
+		lastStart: make(map[string]time.Time),
+		pipeline:  []string{"download", traceTestStage},
+		webhooks:  []Webhook{{URL: hookSrv.URL}},
+	}
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	rns.SetTracerProvider(tp)
+
+	_, reqSpan := tp.Tracer("test").Start(context.Background(), "request")
+	reqSpan.End()
+
+	job, err := NewJob(rns, docSrv.URL+"/doc.pdf", "", 0, 0, 0, 0, false, false, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer job.removeAll()
+	job.spanContext = reqSpan.SpanContext()
+	job.state = JobStarting
+	job.process(context.Background())
+	if state := job.State(); state != JobFinished {
+		t.Fatalf("job %s: %v", jobStateText(state), job.Error)
+	}
+
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	deadline := time.Now().Add(10 * time.Second)
+	for byName["webhook"] == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		for _, span := range sr.Ended() {
+			byName[span.Name()] = span
+		}
+	}
+
+	traceID := reqSpan.SpanContext().TraceID()
+	for child, parent := range map[string]string{
+		"rinse.job":                     "request",
+		"rinse.stage.download":          "rinse.job",
+		"download":                      "rinse.stage.download",
+		"rinse.stage." + traceTestStage: "rinse.job",
+		"runsc":                         "rinse.stage." + traceTestStage,
+		"webhook":                       "request",
+	} {
+		span := byName[child]
+		if span == nil {
+			t.Errorf("no %q span", child)
+			continue
+		}
+		if span.SpanContext().TraceID() != traceID {
+			t.Errorf("%q span not in the trace of the request", child)
+		}
+		if p := byName[parent]; p == nil || span.Parent().SpanID() != p.SpanContext().SpanID() {
+			t.Errorf("%q span is not a child of %q", child, parent)
+		}
+	}
+
+	if runsc := byName["runsc"]; runsc != nil {
+		if v := spanAttr(runsc, "rinse.runsc.id"); v.AsString() != job.UUID.String() {
+			t.Errorf("rinse.runsc.id %q", v.AsString())
+		}
+		if v := spanAttr(runsc, "rinse.runsc.command"); !slices.Equal(v.AsStringSlice(), []string{"echo", "hello"}) {
+			t.Errorf("rinse.runsc.command %v", v.AsStringSlice())
+		}
+		if v := spanAttr(runsc, "rinse.runsc.exit_code"); v.AsInt64() != 3 {
+			t.Errorf("rinse.runsc.exit_code %d", v.AsInt64())
+		}
+	}
+
+	for name, tps := range map[string]*traceparents{"download": &downloads, "webhook": &webhooks} {
+		if span := byName[name]; span != nil {
+			if seen := tps.get(); !slices.Contains(seen, traceparent(span.SpanContext())) {
+				t.Errorf("%s traceparent %q, want %q", name, seen, traceparent(span.SpanContext()))
+			}
+		}
+	}
+}
+
+func TestSetTracerProviderWhileRunning(t *testing.T) {
+	rns := &Rinse{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			_, span := rns.tracer().Start(ctx, "background")
+			span.End()
+		}
+	}()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+	rns.SetTracerProvider(tp)
+	deadline := time.Now().Add(10 * time.Second)
+	for len(sr.Ended()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+	if len(sr.Ended()) == 0 {
+		t.Error("no spans after SetTracerProvider")
+	}
+}
@@ -0,0 +1,42 @@
+package rinser
+
+import (
+	"strings"
+
+	"github.com/linkdata/jaws"
+)
+
+type uiAllowedTypes struct {
+	*Rinse
+	v string
+}
+
+func (u *uiAllowedTypes) JawsClick(e *jaws.Element, data jaws.Click) (err error) {
+	var types []string
+	for _, s1 := range strings.Split(u.v, ",") {
+		for _, s2 := range strings.Split(s1, " ") {
+			if s2 = strings.TrimSpace(s2); s2 != "" {
+				types = append(types, s2)
+			}
+		}
+	}
+	if err = u.SetAllowedTypes(types); err == nil {
+		u.v = strings.Join(u.AllowedTypes(), ", ")
+		e.Dirty(u)
+		err = u.saveSettings()
+	}
+	return
+}
+
+func (u *uiAllowedTypes) JawsSet(e *jaws.Element, v string) (err error) {
+	u.v = v
+	return
+}
+
+func (u *uiAllowedTypes) JawsGet(e *jaws.Element) string {
+	return u.v
+}
+
+func (rns *Rinse) UiAllowedTypes() *uiAllowedTypes {
+	return &uiAllowedTypes{Rinse: rns, v: strings.Join(rns.AllowedTypes(), ", ")}
+}
@@ -0,0 +1,36 @@
+package rinser
+
+import (
+	"html/template"
+	"slices"
+
+	"github.com/linkdata/jaws"
+)
+
+var colorModeNames = map[string]string{
+	"color": "Colour",
+	"gray":  "Grayscale",
+	"mono":  "Monochrome",
+}
+
+type uiColorMode struct{ *Rinse }
+
+// JawsClick implements jaws.ClickHandler.
+func (u uiColorMode) JawsClick(e *jaws.Element, data jaws.Click) (err error) {
+	u.mu.Lock()
+	i := slices.Index(ColorModes, u.colorMode)
+	u.colorMode = ColorModes[(i+1)%len(ColorModes)]
+	u.mu.Unlock()
+	e.Dirty(u)
+	return u.saveSettings()
+}
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (u uiColorMode) JawsGetHTML(rq *jaws.Element) template.HTML {
+	_, colorMode, _ := u.Rendering()
+	return template.HTML(colorModeNames[colorMode]) // #nosec G203
+}
+
+func (rns *Rinse) UiColorMode() jaws.ClickHandler {
+	return uiColorMode{rns}
+}
@@ -0,0 +1,37 @@
+package rinser
+
+import (
+	"html/template"
+	"strconv"
+
+	"github.com/linkdata/jaws"
+	"github.com/linkdata/jaws/lib/bind"
+)
+
+type uiDpi struct{ *Rinse }
+
+func (u uiDpi) Text() string {
+	dpi, _, _ := u.Rendering()
+	return strconv.Itoa(dpi) + " DPI"
+}
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (u uiDpi) JawsGetHTML(rq *jaws.Element) template.HTML {
+	return template.HTML(u.Text()) // #nosec G203
+}
+
+func (u uiDpi) JawsGet(e *jaws.Element) float64 {
+	dpi, _, _ := u.Rendering()
+	return float64(dpi)
+}
+
+func (u uiDpi) JawsSet(e *jaws.Element, v float64) (err error) {
+	u.mu.Lock()
+	u.dpi = min(MaxDpi, max(MinDpi, int(v)))
+	u.mu.Unlock()
+	return u.saveSettings()
+}
+
+func (rns *Rinse) UiDpi() bind.HTMLGetter {
+	return uiDpi{rns}
+}
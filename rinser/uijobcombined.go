@@ -0,0 +1,21 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+)
+
+type uiJobCombined struct {
+	*Job
+}
+
+func (ui uiJobCombined) JawsUpdate(e *jaws.Element) {
+	if ui.State() == JobFinished && ui.HasChildren() && !ui.IsArchive() {
+		e.RemoveAttr("hidden")
+	} else {
+		e.SetAttr("hidden", "")
+	}
+}
+
+func (job *Job) UiJobCombined() jaws.Updater {
+	return uiJobCombined{job}
+}
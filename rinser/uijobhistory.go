@@ -0,0 +1,59 @@
+package rinser
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/linkdata/bytecount"
+	"github.com/linkdata/jaws"
+	"github.com/linkdata/jaws/lib/bind"
+)
+
+type uiJobHistory struct{ *Job }
+
+var historyColors = []string{"bg-primary", "bg-info", "bg-success", "bg-warning", "bg-secondary"}
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (ui uiJobHistory) JawsGetHTML(e *jaws.Element) template.HTML {
+	history := ui.GetHistory()
+	now := time.Now()
+	var total float64
+	seconds := make([]float64, len(history))
+	for i, rec := range history {
+		seconds[i] = rec.Seconds
+		if rec.Stopped.IsZero() {
+			seconds[i] = now.Sub(rec.Started).Seconds()
+		}
+		total += seconds[i]
+	}
+	var sb strings.Builder
+	if total > 0 {
+		for i, rec := range history {
+			title := fmt.Sprintf("%s: %s", rec.State, prettyDuration(time.Duration(seconds[i]*float64(time.Second)).Round(time.Millisecond)))
+			if rec.Runs > 0 {
+				title += fmt.Sprintf(", exit code %d", rec.ExitCode)
+			}
+			if rec.Bytes != 0 {
+				sign := "+"
+				if rec.Bytes < 0 {
+					sign = "-"
+				}
+				title += fmt.Sprintf(", %s%v", sign, bytecount.N(max(rec.Bytes, -rec.Bytes)))
+			}
+			color := historyColors[i%len(historyColors)]
+			if rec.ExitCode != 0 {
+				color = "bg-danger"
+			}
+			fmt.Fprintf(&sb, `<div class="progress" role="progressbar" style="width: %.2f%%" title="%s"><div class="progress-bar %s"></div></div>`,
+				100*seconds[i]/total, html.EscapeString(title), color)
+		}
+	}
+	return template.HTML(sb.String()) // #nosec G203
+}
+
+func (job *Job) UiHistory() (ui bind.HTMLGetter) {
+	return uiJobHistory{job}
+}
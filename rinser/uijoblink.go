@@ -16,7 +16,11 @@ type uiJobLink struct{ *Job }
 func (ui uiJobLink) JawsGetHTML(rq *jaws.Element) template.HTML {
 	var s string
 	if ui.State() == JobFinished {
-		s = fmt.Sprintf(`<a target="_blank" href="/jobs/%s/rinsed">%s</a>`, ui.UUID, html.EscapeString(ui.ResultName()))
+		endpoint := "rinsed"
+		if ui.IsArchive() {
+			endpoint = "combined"
+		}
+		s = fmt.Sprintf(`<a target="_blank" href="/jobs/%s/%s">%s</a>`, ui.UUID, endpoint, html.EscapeString(ui.ResultName()))
 	} else {
 		s = html.EscapeString(ui.Name)
 	}
@@ -0,0 +1,21 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+)
+
+type uiJobManifest struct {
+	*Job
+}
+
+func (ui uiJobManifest) JawsUpdate(e *jaws.Element) {
+	if ui.HasManifest() {
+		e.RemoveAttr("hidden")
+	} else {
+		e.SetAttr("hidden", "")
+	}
+}
+
+func (job *Job) UiJobManifest() jaws.Updater {
+	return uiJobManifest{job}
+}
@@ -0,0 +1,20 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+)
+
+type uiJobPriority struct{ *Job }
+
+func (ui uiJobPriority) JawsGet(e *jaws.Element) float64 {
+	return float64(ui.GetPriority())
+}
+
+func (ui uiJobPriority) JawsSet(e *jaws.Element, v float64) (err error) {
+	ui.SetPriority(int(v))
+	return
+}
+
+func (job *Job) UiJobPriority() uiJobPriority {
+	return uiJobPriority{job}
+}
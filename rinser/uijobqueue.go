@@ -0,0 +1,25 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+)
+
+type uiJobQueue struct{ *Job }
+
+// JawsClick implements jaws.ClickHandler.
+func (ui uiJobQueue) JawsClick(e *jaws.Element, data jaws.Click) (err error) {
+	switch data.Name {
+	case "jobtop":
+		return ui.Rinse.MoveJob(ui.Job, 0)
+	case "jobup":
+		if pos := ui.Rinse.QueuePosition(ui.Job); pos > 0 {
+			return ui.Rinse.MoveJob(ui.Job, pos-1)
+		}
+		return nil
+	}
+	return jaws.ErrEventUnhandled
+}
+
+func (job *Job) UiJobQueue() jaws.ClickHandler {
+	return uiJobQueue{job}
+}
@@ -0,0 +1,30 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+)
+
+type uiJobRetry struct {
+	*Job
+}
+
+// JawsUpdate implements jaws.Updater.
+func (ui uiJobRetry) JawsUpdate(e *jaws.Element) {
+	if ui.Retryable() {
+		e.RemoveAttr("hidden")
+	} else {
+		e.SetAttr("hidden", "")
+	}
+}
+
+// JawsClick implements jaws.ClickHandler.
+func (ui uiJobRetry) JawsClick(e *jaws.Element, data jaws.Click) (err error) {
+	if data.Name == "jobretry" {
+		return ui.Retry()
+	}
+	return jaws.ErrEventUnhandled
+}
+
+func (job *Job) UiJobRetry() uiJobRetry {
+	return uiJobRetry{job}
+}
@@ -0,0 +1,39 @@
+package rinser
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+
+	"github.com/linkdata/jaws"
+	"github.com/linkdata/jaws/lib/bind"
+)
+
+type uiJobRisk struct{ *Job }
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (ui uiJobRisk) JawsGetHTML(e *jaws.Element) template.HTML {
+	var s string
+	if risk := ui.GetRisk(); risk != nil {
+		riskclass := "text-bg-light"
+		switch risk.Level {
+		case "low":
+			riskclass = "text-bg-info"
+		case "medium":
+			riskclass = "text-bg-warning"
+		case "high":
+			riskclass = "text-bg-danger"
+		}
+		title := "No risky content found"
+		if len(risk.Findings) > 0 {
+			title = risk.String()
+		}
+		s = fmt.Sprintf(`<span class="badge %s" data-toggle="tooltip" title="%s">Risk %d</span>`,
+			riskclass, html.EscapeString(title), risk.Score)
+	}
+	return template.HTML(s) // #nosec G203
+}
+
+func (job *Job) UiRisk() (ui bind.HTMLGetter) {
+	return uiJobRisk{job}
+}
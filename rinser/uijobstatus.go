@@ -19,26 +19,15 @@ func jobStateText(n JobState) (statetxt string) {
 		statetxt = "Waiting"
 	case JobStarting:
 		statetxt = "Starting"
-	case JobDownload:
-		statetxt = "Downloading"
-	case JobExtractMeta:
-		statetxt = "Extract Metadata"
-	case JobDetectLanguage:
-		statetxt = "Detect Language"
-	case JobDocToPdf:
-		statetxt = "Converting"
-	case JobPdfToImages:
-		statetxt = "Rendering"
-	case JobTesseract:
-		statetxt = "Scanning"
-	case JobEnding:
-		statetxt = "Cleanup"
 	case JobFinished:
 		statetxt = "Finished"
 	case JobFailed:
 		statetxt = "Failed"
 	default:
-		statetxt = strconv.Itoa(int(n))
+		var ok bool
+		if statetxt, ok = stageName(n); !ok {
+			statetxt = strconv.Itoa(int(n))
+		}
 	}
 	return
 }
@@ -57,7 +46,9 @@ func (ui uiJobStatus) JawsGetHTML(e *jaws.Element) template.HTML {
 			imgdone++
 		}
 	}
-	ui.Pages = imgcount
+	if imgcount > 0 {
+		ui.Pages = imgcount
+	}
 	ui.mu.Unlock()
 
 	statetxt := jobStateText(state)
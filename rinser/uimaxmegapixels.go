@@ -0,0 +1,39 @@
+package rinser
+
+import (
+	"html/template"
+	"strconv"
+
+	"github.com/linkdata/jaws"
+	"github.com/linkdata/jaws/lib/bind"
+)
+
+type uiMaxMegapixels struct{ *Rinse }
+
+func (u uiMaxMegapixels) Text() string {
+	if _, _, n := u.Rendering(); n > 0 {
+		return strconv.Itoa(n) + " MP"
+	}
+	return "unlimited"
+}
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (u uiMaxMegapixels) JawsGetHTML(rq *jaws.Element) template.HTML {
+	return template.HTML(u.Text()) // #nosec G203
+}
+
+func (u uiMaxMegapixels) JawsGet(e *jaws.Element) float64 {
+	_, _, n := u.Rendering()
+	return float64(n)
+}
+
+func (u uiMaxMegapixels) JawsSet(e *jaws.Element, v float64) (err error) {
+	u.mu.Lock()
+	u.maxMegapixels = max(0, int(v))
+	u.mu.Unlock()
+	return u.saveSettings()
+}
+
+func (rns *Rinse) UiMaxMegapixels() bind.HTMLGetter {
+	return uiMaxMegapixels{rns}
+}
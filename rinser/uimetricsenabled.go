@@ -0,0 +1,23 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+)
+
+type uiMetricsEnabled struct{ *Rinse }
+
+func (u uiMetricsEnabled) JawsGet(e *jaws.Element) bool {
+	enabled, _ := u.MetricsPolicy()
+	return enabled
+}
+
+func (u uiMetricsEnabled) JawsSet(e *jaws.Element, v bool) (err error) {
+	u.mu.Lock()
+	u.metricsEnabled = v
+	u.mu.Unlock()
+	return u.saveSettings()
+}
+
+func (rns *Rinse) UiMetricsEnabled() any {
+	return uiMetricsEnabled{rns}
+}
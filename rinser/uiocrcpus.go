@@ -0,0 +1,43 @@
+package rinser
+
+import (
+	"html/template"
+	"runtime"
+	"strconv"
+
+	"github.com/linkdata/jaws"
+)
+
+type uiOcrCPUs struct{ *Rinse }
+
+func (u uiOcrCPUs) Text() string {
+	return strconv.Itoa(u.OcrCPUs())
+}
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (u uiOcrCPUs) JawsGetHTML(rq *jaws.Element) template.HTML {
+	return template.HTML(u.Text()) // #nosec G203
+}
+
+func (u uiOcrCPUs) JawsGet(e *jaws.Element) float64 {
+	return float64(u.OcrCPUs())
+}
+
+func (u uiOcrCPUs) JawsSet(e *jaws.Element, v float64) (err error) {
+	u.mu.Lock()
+	if n := int(v); n > 0 {
+		u.ocrCPUs = min(maxOcrCPUs, n)
+	}
+	u.mu.Unlock()
+	go u.resizeOcrCPUs()
+	return u.saveSettings()
+}
+
+// RangeMax returns the upper bound for the setup page slider.
+func (u uiOcrCPUs) RangeMax() int {
+	return max(8, runtime.NumCPU())
+}
+
+func (rns *Rinse) UiOcrCPUs() uiOcrCPUs {
+	return uiOcrCPUs{rns}
+}
@@ -0,0 +1,46 @@
+package rinser
+
+import (
+	"strings"
+
+	"github.com/linkdata/jaws"
+)
+
+type uiPipeline struct {
+	*Rinse
+	v string
+}
+
+func (u *uiPipeline) JawsClick(e *jaws.Element, data jaws.Click) (err error) {
+	var ids []string
+	for _, s1 := range strings.Split(u.v, ",") {
+		for _, s2 := range strings.Split(s1, " ") {
+			if s2 = strings.TrimSpace(s2); s2 != "" {
+				ids = append(ids, s2)
+			}
+		}
+	}
+	if err = u.SetPipeline(ids); err == nil {
+		u.v = strings.Join(u.Pipeline(), ", ")
+		e.Dirty(u)
+		err = u.saveSettings()
+	}
+	return
+}
+
+func (u *uiPipeline) JawsSet(e *jaws.Element, v string) (err error) {
+	u.v = v
+	return
+}
+
+func (u *uiPipeline) JawsGet(e *jaws.Element) string {
+	return u.v
+}
+
+func (u *uiPipeline) Available() string {
+	return strings.Join(StageIDs(), ", ")
+}
+
+func (rns *Rinse) UiPipeline() *uiPipeline {
+	return &uiPipeline{Rinse: rns, v: strings.Join(rns.Pipeline(), ", ")}
+}
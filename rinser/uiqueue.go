@@ -0,0 +1,20 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+	"github.com/linkdata/jaws/lib/ui"
+)
+
+type uiQueue struct{ *Rinse }
+
+// JawsContains implements jaws.Container.
+func (u uiQueue) JawsContains(e *jaws.Element) (contents []jaws.UI) {
+	for _, job := range u.QueuedJobs() {
+		contents = append(contents, ui.NewTemplate("", "queuejob.html", job))
+	}
+	return
+}
+
+func (rns *Rinse) UiQueue() jaws.Container {
+	return uiQueue{rns}
+}
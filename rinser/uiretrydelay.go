@@ -0,0 +1,39 @@
+package rinser
+
+import (
+	"html/template"
+	"time"
+
+	"github.com/linkdata/jaws"
+	"github.com/linkdata/jaws/lib/bind"
+)
+
+type uiRetryDelay struct{ *Rinse }
+
+func (u uiRetryDelay) Text() string {
+	if _, d := u.RetryPolicy(); d > 0 {
+		return prettyDuration(d)
+	}
+	return "immediately"
+}
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (u uiRetryDelay) JawsGetHTML(rq *jaws.Element) template.HTML {
+	return template.HTML(u.Text()) // #nosec G203
+}
+
+func (u uiRetryDelay) JawsGet(e *jaws.Element) float64 {
+	_, d := u.RetryPolicy()
+	return float64(d / time.Second)
+}
+
+func (u uiRetryDelay) JawsSet(e *jaws.Element, v float64) (err error) {
+	u.mu.Lock()
+	u.retryDelaySec = max(0, int(v))
+	u.mu.Unlock()
+	return u.saveSettings()
+}
+
+func (rns *Rinse) UiRetryDelay() bind.HTMLGetter {
+	return uiRetryDelay{rns}
+}
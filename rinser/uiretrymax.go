@@ -0,0 +1,39 @@
+package rinser
+
+import (
+	"html/template"
+	"strconv"
+
+	"github.com/linkdata/jaws"
+	"github.com/linkdata/jaws/lib/bind"
+)
+
+type uiRetryMax struct{ *Rinse }
+
+func (u uiRetryMax) Text() string {
+	if n, _ := u.RetryPolicy(); n > 0 {
+		return strconv.Itoa(n)
+	}
+	return "disabled"
+}
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (u uiRetryMax) JawsGetHTML(rq *jaws.Element) template.HTML {
+	return template.HTML(u.Text()) // #nosec G203
+}
+
+func (u uiRetryMax) JawsGet(e *jaws.Element) float64 {
+	n, _ := u.RetryPolicy()
+	return float64(n)
+}
+
+func (u uiRetryMax) JawsSet(e *jaws.Element, v float64) (err error) {
+	u.mu.Lock()
+	u.retryMax = max(0, int(v))
+	u.mu.Unlock()
+	return u.saveSettings()
+}
+
+func (rns *Rinse) UiRetryMax() bind.HTMLGetter {
+	return uiRetryMax{rns}
+}
@@ -0,0 +1,22 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+)
+
+type uiStampEnabled struct{ *Rinse }
+
+func (u uiStampEnabled) JawsGet(e *jaws.Element) bool {
+	return u.StampSettings().Enabled
+}
+
+func (u uiStampEnabled) JawsSet(e *jaws.Element, v bool) (err error) {
+	u.mu.Lock()
+	u.stamp.Enabled = v
+	u.mu.Unlock()
+	return u.saveSettings()
+}
+
+func (rns *Rinse) UiStampEnabled() any {
+	return uiStampEnabled{rns}
+}
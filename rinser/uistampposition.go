@@ -0,0 +1,35 @@
+package rinser
+
+import (
+	"html/template"
+	"slices"
+
+	"github.com/linkdata/jaws"
+)
+
+var stampPositionNames = map[string]string{
+	StampFooter:    "Footer",
+	StampHeader:    "Header",
+	StampWatermark: "Watermark",
+}
+
+type uiStampPosition struct{ *Rinse }
+
+// JawsClick implements jaws.ClickHandler.
+func (u uiStampPosition) JawsClick(e *jaws.Element, data jaws.Click) (err error) {
+	u.mu.Lock()
+	i := slices.Index(StampPositions, u.stamp.Position)
+	u.stamp.Position = StampPositions[(i+1)%len(StampPositions)]
+	u.mu.Unlock()
+	e.Dirty(u)
+	return u.saveSettings()
+}
+
+// JawsGetHTML implements bind.HTMLGetter.
+func (u uiStampPosition) JawsGetHTML(rq *jaws.Element) template.HTML {
+	return template.HTML(stampPositionNames[u.StampSettings().Position]) // #nosec G203
+}
+
+func (rns *Rinse) UiStampPosition() jaws.ClickHandler {
+	return uiStampPosition{rns}
+}
@@ -0,0 +1,32 @@
+package rinser
+
+import (
+	"github.com/linkdata/jaws"
+)
+
+type uiStampTemplate struct {
+	*Rinse
+	v string
+}
+
+func (u *uiStampTemplate) JawsClick(e *jaws.Element, data jaws.Click) (err error) {
+	ss := u.StampSettings()
+	ss.Template = u.v
+	if err = u.SetStampSettings(ss); err == nil {
+		err = u.saveSettings()
+	}
+	return
+}
+
+func (u *uiStampTemplate) JawsSet(e *jaws.Element, v string) (err error) {
+	u.v = v
+	return
+}
+
+func (u *uiStampTemplate) JawsGet(e *jaws.Element) string {
+	return u.v
+}
+
+func (rns *Rinse) UiStampTemplate() *uiStampTemplate {
+	return &uiStampTemplate{Rinse: rns, v: rns.StampSettings().Template}
+}
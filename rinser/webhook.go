@@ -0,0 +1,177 @@
+package rinser
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrIllegalCallback = errors.New("illegal callback URL")
+var ErrWebhookStatus = errors.New("webhook failed")
+
+const (
+	WebhookEventFinished = "job.finished"
+	WebhookEventFailed   = "job.failed"
+)
+
+const (
+	webhookAttempts = 6
+	webhookDelay    = 10 * time.Second // doubles after each failed attempt
+	webhookTimeout  = 30 * time.Second
+)
+
+// Webhook is a URL that is sent the finished and failed events of all jobs.
+type Webhook struct {
+	URL    string
+	Secret string // HMAC-SHA256 key used to sign the payload, if not empty
+}
+
+// WebhookPayload is the JSON POSTed to webhooks and job callbacks.
+type WebhookPayload struct {
+	Event string    `json:"event" example:"job.finished"`
+	Time  time.Time `json:"time" example:"2024-01-01T12:00:00+00:00" format:"dateTime"`
+	Job   *Job      `json:"job"`
+}
+
+// CheckCallback returns an error if s is neither empty nor an HTTP(S) URL.
+func CheckCallback(s string) (err error) {
+	if s != "" {
+		var u *url.URL
+		if u, err = url.Parse(s); err == nil && (!hasHTTPScheme(u.Scheme+":") || u.Host == "") {
+			err = errors.New(s)
+		}
+		if err != nil {
+			err = fmt.Errorf("%w: %w", ErrIllegalCallback, err)
+		}
+	}
+	return
+}
+
+// Webhooks returns the webhooks from the settings.
+func (rns *Rinse) Webhooks() (hooks []Webhook) {
+	rns.mu.Lock()
+	hooks = slices.Clone(rns.webhooks)
+	rns.mu.Unlock()
+	return
+}
+
+// WebhookSignature returns the value of the X-Rinse-Signature header for a
+// payload signed with secret, where timestamp is the X-Rinse-Timestamp header.
+func WebhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(timestamp + "."))
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhooks sends the job's finished or failed event
+// to the job's callback and to the configured webhooks.
+func (job *Job) notifyWebhooks() {
+	job.mu.Lock()
+	state := job.state
+	callback := job.Callback
+	shutdown := job.shutdown
+	job.mu.Unlock()
+
+	event := WebhookEventFinished
+	switch state {
+	case JobFinished:
+	case JobFailed:
+		event = WebhookEventFailed
+	default:
+		return
+	}
+
+	hooks := job.Rinse.Webhooks()
+	if callback != "" {
+		job.Rinse.mu.Lock()
+		hooks = append(hooks, Webhook{URL: callback, Secret: job.Rinse.callbackSecret})
+		job.Rinse.mu.Unlock()
+	}
+	if len(hooks) > 0 && !shutdown {
+		body, err := json.Marshal(WebhookPayload{Event: event, Time: time.Now(), Job: job})
+		if err == nil {
+			ctx := trace.ContextWithSpanContext(job.Rinse.closeContext(), job.spanContext)
+			for _, hook := range hooks {
+				job.Rinse.goWebhook(func() { job.Rinse.deliverWebhook(ctx, hook, event, body) })
+			}
+		} else {
+			job.Rinse.Error("notifyWebhooks", "job", job.Name, "err", err)
+		}
+	}
+}
+
+// goWebhook runs fn in a goroutine that Close waits for, unless rns is closed.
+func (rns *Rinse) goWebhook(fn func()) {
+	rns.mu.Lock()
+	defer rns.mu.Unlock()
+	if !rns.closed {
+		rns.webhookWg.Go(fn)
+	}
+}
+
+// deliverWebhook POSTs the payload to the webhook, retrying with an increasing
+// delay until it succeeds, we run out of attempts or ctx is cancelled.
+func (rns *Rinse) deliverWebhook(ctx context.Context, hook Webhook, event string, body []byte) {
+	var err error
+	delay := webhookDelay
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			rns.Warn("webhook", "url", hook.URL, "event", event, "attempt", attempt-1, "err", err)
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+		if err = rns.postWebhook(ctx, hook, event, body); err == nil || ctx.Err() != nil {
+			return
+		}
+	}
+	rns.Error("webhook", "url", hook.URL, "event", event, "err", err)
+}
+
+func (rns *Rinse) postWebhook(ctx context.Context, hook Webhook, event string, body []byte) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	ctx, span := rns.tracer().Start(ctx, "webhook", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rinse.webhook.event", event)))
+	defer func() { endSpan(span, err) }()
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body)); err == nil {
+		injectTrace(ctx, req)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Rinse-Event", event)
+		if hook.Secret != "" {
+			timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+			req.Header.Set("X-Rinse-Timestamp", timestamp)
+			req.Header.Set("X-Rinse-Signature", WebhookSignature(hook.Secret, timestamp, body))
+		}
+		var resp *http.Response
+		if resp, err = rns.getClient().Do(req); err == nil /* #nosec G704 */ {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			_ = resp.Body.Close()
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				err = fmt.Errorf("%w: %s", ErrWebhookStatus, resp.Status)
+			}
+		}
+	}
+	return
+}
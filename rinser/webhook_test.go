@@ -0,0 +1,75 @@
+package rinser
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/linkdata/webserv"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	var hdr http.Header
+	var got []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(hw http.ResponseWriter, hr *http.Request) {
+		hdr = hr.Header.Clone()
+		got, _ = io.ReadAll(hr.Body)
+	}))
+	defer srv.Close()
+
+	rns := &Rinse{Config: &webserv.Config{}}
+	body := []byte(`{"event":"job.finished"}`)
+	if err := rns.postWebhook(context.Background(), Webhook{URL: srv.URL, Secret: "s3cret"}, WebhookEventFinished, body); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(body) {
+		t.Errorf("body %q", got)
+	}
+	timestamp := hdr.Get("X-Rinse-Timestamp")
+	if ts, err := strconv.ParseInt(timestamp, 10, 64); err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("X-Rinse-Timestamp %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	_, _ = mac.Write([]byte(timestamp + "." + string(body)))
+	if sig := hdr.Get("X-Rinse-Signature"); sig != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("X-Rinse-Signature %q", sig)
+	}
+	if sig := WebhookSignature("s3cret", timestamp+"0", body); sig == hdr.Get("X-Rinse-Signature") {
+		t.Error("signature does not cover the timestamp")
+	}
+}
+
+func TestWebhookRetryStopsOnClose(t *testing.T) {
+	posted := make(chan struct{}, webhookAttempts)
+	srv := httptest.NewServer(http.HandlerFunc(func(hw http.ResponseWriter, hr *http.Request) {
+		posted <- struct{}{}
+		hw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	rns := &Rinse{Config: &webserv.Config{}}
+	ctx := rns.closeContext()
+	rns.goWebhook(func() { rns.deliverWebhook(ctx, Webhook{URL: srv.URL}, WebhookEventFailed, []byte("{}")) })
+	select {
+	case <-posted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("webhook not posted")
+	}
+	start := time.Now()
+	rns.Close()
+	if elapsed := time.Since(start); elapsed >= webhookDelay {
+		t.Errorf("Close waited %v for the retry", elapsed)
+	}
+	if len(posted) != 0 {
+		t.Errorf("%d retries after the first attempt", len(posted))
+	}
+	rns.goWebhook(func() { t.Error("webhook started after Close") })
+	rns.Close()
+}